@@ -0,0 +1,117 @@
+// Artwork cache and HTTP server
+//
+// Caches artwork embedded in track tags by playlist id and serves it
+// from /artwork/{playlistID} so displays can show album art for
+// providers that do not return it themselves.
+
+package artwork
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"player/logger"
+	"player/tags"
+)
+
+// Path artwork is served from
+const path = "/artwork/"
+
+// Global artwork cache
+var cache = &Cache{
+	Config:   NewConfig(),
+	pictures: make(map[string]*tags.Picture),
+}
+
+// Add a picture to the global cache
+func Add(id string, p *tags.Picture) {
+	cache.Add(id, p)
+}
+
+// Returns the global cache URL for a playlist id
+func URL(id string) string {
+	return cache.URL(id)
+}
+
+// A bounded in memory cache of artwork, the oldest picture is
+// evicted once the cache is full
+type Cache struct {
+	Config   Configurer
+	lock     sync.RWMutex
+	pictures map[string]*tags.Picture
+	order    []string // Playlist ids, oldest first
+}
+
+// Adds a picture to the cache
+func (c *Cache) Add(id string, p *tags.Picture) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.pictures[id]; !ok {
+		c.order = append(c.order, id)
+	}
+	c.pictures[id] = p
+	for len(c.order) > 0 && len(c.order) > c.Config.Size() {
+		delete(c.pictures, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// Gets a picture from the cache, nil if not cached
+func (c *Cache) Get(id string) *tags.Picture {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.pictures[id]
+}
+
+// Returns the URL the artwork for a playlist id is served from
+func (c *Cache) URL(id string) string {
+	return strings.TrimRight(c.Config.URL(), "/") + path + id
+}
+
+// Serves artwork for a playlist id
+func (c *Cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := c.Get(strings.TrimPrefix(r.URL.Path, path))
+	if p == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", p.MIME)
+	w.Write(p.Data)
+}
+
+// Artwork HTTP server
+type Server struct {
+	server *http.Server
+}
+
+// Start serving artwork, blocks until closed
+func (s *Server) Listen() error {
+	logger.WithField("address", s.server.Addr).Debug("start artwork server")
+	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.WithError(err).Error("artwork server error")
+		return err
+	}
+	return nil
+}
+
+// Close the artwork server
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// Constructs a new artwork server serving the global cache
+func NewServer(c Configurer) *Server {
+	mux := http.NewServeMux()
+	mux.Handle(path, cache)
+	return &Server{
+		server: &http.Server{
+			Addr:    c.Address(),
+			Handler: mux,
+		},
+	}
+}
@@ -0,0 +1,43 @@
+package artwork
+
+import "github.com/spf13/viper"
+
+const (
+	vAddress = "artwork.address"
+	vURL     = "artwork.url"
+	vSize    = "artwork.size"
+)
+
+func init() {
+	viper.SetDefault(vAddress, ":8765")
+	viper.SetDefault(vURL, "http://localhost:8765")
+	viper.SetDefault(vSize, 16)
+	viper.BindEnv(vAddress, vURL, vSize)
+}
+
+type Configurer interface {
+	Address() string
+	URL() string
+	Size() int
+}
+
+type Config struct{}
+
+// Address the artwork http server listens on
+func (c Config) Address() string {
+	return viper.GetString(vAddress)
+}
+
+// Base URL clients use to reach the artwork server
+func (c Config) URL() string {
+	return viper.GetString(vURL)
+}
+
+// Maximum number of pictures held in the cache
+func (c Config) Size() int {
+	return viper.GetInt(vSize)
+}
+
+func NewConfig() Config {
+	return Config{}
+}
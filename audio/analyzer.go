@@ -0,0 +1,192 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+	"sync"
+	"time"
+
+	"player/logger"
+)
+
+// Number of frames analysed for each levels update, must be a power of 2
+const ANALYZER_WINDOW = 2048
+
+// Lowest and highest spectrum band frequencies
+const (
+	minBandFrequency = 20.0
+	maxBandFrequency = 20000.0
+)
+
+// Audio levels of the most recently played samples, all values are
+// linear amplitudes between 0 and 1
+type Levels struct {
+	RMS   []float64 // Per channel RMS level
+	Peak  []float64 // Per channel peak level
+	Bands []float64 // Spectrum band levels, low to high frequency
+}
+
+// Analyzer taps the PCM written to the output and periodically
+// publishes the levels and spectrum of the most recent samples
+type Analyzer struct {
+	// Exported Fields
+	Config AnalyzerConfigurer
+	// Unexported Fields
+	lock    *sync.Mutex
+	window  []float32 // Ring buffer of the latest samples
+	pos     int       // Next write position in the ring buffer
+	fresh   bool      // Samples have been tapped since the last update
+	hann    []float64
+	levelsC chan Levels
+	// Close orchestration
+	closeWg *sync.WaitGroup
+	closeC  chan bool
+}
+
+// Copies samples into the analysis window, never blocks on analysis
+func (a *Analyzer) Tap(samples []float32) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for len(samples) > 0 {
+		n := copy(a.window[a.pos:], samples)
+		samples = samples[n:]
+		a.pos = (a.pos + n) % len(a.window)
+	}
+	a.fresh = true
+}
+
+// Returns the analysed levels channel
+func (a *Analyzer) Levels() <-chan Levels {
+	return (<-chan Levels)(a.levelsC)
+}
+
+// Returns the analysis window in playback order as per channel floats,
+// once the window has been analysed with no new samples it is cleared
+// so a final silent update is published
+func (a *Analyzer) snapshot() ([][]float64, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	frames := len(a.window) / CHANNELS
+	channels := make([][]float64, CHANNELS)
+	for c := range channels {
+		channels[c] = make([]float64, frames)
+	}
+	silent := true
+	for i := 0; i < len(a.window); i++ {
+		s := a.window[(a.pos+i)%len(a.window)]
+		if s != 0 {
+			silent = false
+		}
+		channels[i%CHANNELS][i/CHANNELS] = float64(s)
+	}
+	if !a.fresh {
+		if silent {
+			return nil, false
+		}
+		for i := range a.window {
+			a.window[i] = 0
+		}
+		// Published as cleared so levels fall to silence
+		for c := range channels {
+			channels[c] = make([]float64, frames)
+		}
+	}
+	a.fresh = false
+	return channels, true
+}
+
+// Calculates levels for the per channel samples
+func (a *Analyzer) analyse(channels [][]float64) Levels {
+	levels := Levels{
+		RMS:   make([]float64, len(channels)),
+		Peak:  make([]float64, len(channels)),
+		Bands: make([]float64, a.Config.AnalyzerBands()),
+	}
+	n := len(channels[0])
+	mono := make([]complex128, n)
+	for c, samples := range channels {
+		var sum float64
+		for i, s := range samples {
+			sum += s * s
+			levels.Peak[c] = math.Max(levels.Peak[c], math.Abs(s))
+			mono[i] += complex(s*a.hann[i]/float64(len(channels)), 0)
+		}
+		levels.RMS[c] = math.Sqrt(sum / float64(n))
+	}
+	fft(mono)
+	// Logarithmically spaced bands between the min and max frequency,
+	// each band takes the loudest bin it covers
+	var gain float64
+	for _, w := range a.hann {
+		gain += w
+	}
+	binWidth := float64(SAMPLE_RATE) / float64(n)
+	ratio := math.Pow(maxBandFrequency/minBandFrequency, 1/float64(len(levels.Bands)))
+	for b := range levels.Bands {
+		lo := int(minBandFrequency * math.Pow(ratio, float64(b)) / binWidth)
+		hi := int(minBandFrequency * math.Pow(ratio, float64(b+1)) / binWidth)
+		for bin := lo; bin <= hi && bin < n/2; bin++ {
+			m := 2 * cmplx.Abs(mono[bin]) / gain
+			levels.Bands[b] = math.Min(1, math.Max(levels.Bands[b], m))
+		}
+	}
+	return levels
+}
+
+// Publishes levels at the configured rate until closed, slow consumers
+// miss updates rather than blocking analysis
+func (a *Analyzer) run() {
+	logger.Debug("start audio analyzer")
+	defer logger.Debug("exit audio analyzer")
+	defer a.closeWg.Done()
+	defer close(a.levelsC)
+	rate := a.Config.AnalyzerRate()
+	if rate <= 0 {
+		rate = 1
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.closeC:
+			return
+		case <-ticker.C:
+			channels, ok := a.snapshot()
+			if !ok {
+				continue
+			}
+			select {
+			case a.levelsC <- a.analyse(channels):
+			default:
+			}
+		}
+	}
+}
+
+// Starts publishing levels
+func (a *Analyzer) Start() {
+	a.closeWg.Add(1)
+	go a.run()
+}
+
+// Stops publishing levels
+func (a *Analyzer) Close() error {
+	logger.Debug("close audio analyzer")
+	defer logger.Debug("closed audio analyzer")
+	close(a.closeC)
+	a.closeWg.Wait()
+	return nil
+}
+
+// Constructs a new Analyzer
+func NewAnalyzer(c AnalyzerConfigurer) *Analyzer {
+	return &Analyzer{
+		Config:  c,
+		lock:    &sync.Mutex{},
+		window:  make([]float32, ANALYZER_WINDOW*CHANNELS),
+		hann:    hann(ANALYZER_WINDOW),
+		levelsC: make(chan Levels, 1),
+		closeWg: &sync.WaitGroup{},
+		closeC:  make(chan bool),
+	}
+}
@@ -0,0 +1,141 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testAnalyzerConfig struct{}
+
+func (c testAnalyzerConfig) AnalyzerEnabled() bool { return true }
+func (c testAnalyzerConfig) AnalyzerRate() int     { return 20 }
+func (c testAnalyzerConfig) AnalyzerBands() int    { return 16 }
+
+// Returns interleaved frames of a sine wave on every channel
+func sine(frames int, frequency, amplitude float64) []float32 {
+	samples := make([]float32, frames*CHANNELS)
+	for i := range samples {
+		t := float64(i/CHANNELS) / SAMPLE_RATE
+		samples[i] = float32(amplitude * math.Sin(2*math.Pi*frequency*t))
+	}
+	return samples
+}
+
+// Returns the highest absolute sample of the per channel samples
+func loudest(channels [][]float64) float64 {
+	var max float64
+	for _, samples := range channels {
+		for _, s := range samples {
+			max = math.Max(max, math.Abs(s))
+		}
+	}
+	return max
+}
+
+func TestAnalyzerSnapshot(t *testing.T) {
+	tt := []struct {
+		tname   string
+		tap     []float32
+		ok      bool
+		loudest float64
+	}{
+		{"tapped", sine(ANALYZER_WINDOW, 1000, 0.5), true, 0.5},
+		{"stale", nil, true, 0},
+		{"silent", nil, false, 0},
+		{"tapped again", sine(ANALYZER_WINDOW, 1000, 0.25), true, 0.25},
+	}
+	a := NewAnalyzer(testAnalyzerConfig{})
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			if tc.tap != nil {
+				a.Tap(tc.tap)
+			}
+			channels, ok := a.snapshot()
+			assert.Equal(t, tc.ok, ok)
+			if !ok {
+				return
+			}
+			assert.Len(t, channels, CHANNELS)
+			assert.InDelta(t, tc.loudest, loudest(channels), 0.001)
+		})
+	}
+}
+
+func TestAnalyzerAnalyse(t *testing.T) {
+	// Centred on a bin so none of the tone leaks into its neighbours
+	tone := 46 * float64(SAMPLE_RATE) / ANALYZER_WINDOW
+	a := NewAnalyzer(testAnalyzerConfig{})
+	a.Tap(sine(ANALYZER_WINDOW, tone, 0.5))
+	channels, _ := a.snapshot()
+	levels := a.analyse(channels)
+	for c := 0; c < CHANNELS; c++ {
+		assert.InDelta(t, 0.5/math.Sqrt2, levels.RMS[c], 0.01)
+		assert.InDelta(t, 0.5, levels.Peak[c], 0.01)
+	}
+	// The loudest band covers the tone
+	loudest := 0
+	for b, level := range levels.Bands {
+		if level > levels.Bands[loudest] {
+			loudest = b
+		}
+	}
+	ratio := math.Pow(maxBandFrequency/minBandFrequency, 1/float64(len(levels.Bands)))
+	assert.True(t, minBandFrequency*math.Pow(ratio, float64(loudest)) <= tone)
+	assert.True(t, minBandFrequency*math.Pow(ratio, float64(loudest+1)) >= tone)
+	assert.InDelta(t, 0.5, levels.Bands[loudest], 0.05)
+}
+
+func TestFFT(t *testing.T) {
+	const n = 16
+	tt := []struct {
+		tname    string
+		input    func(i int) float64
+		expected func(bin int) float64 // Expected magnitude of each bin
+	}{
+		{
+			"impulse",
+			func(i int) float64 {
+				if i == 0 {
+					return 1
+				}
+				return 0
+			},
+			func(bin int) float64 { return 1 },
+		},
+		{
+			"dc",
+			func(i int) float64 { return 1 },
+			func(bin int) float64 {
+				if bin == 0 {
+					return n
+				}
+				return 0
+			},
+		},
+		{
+			"cosine",
+			func(i int) float64 { return math.Cos(2 * math.Pi * 3 * float64(i) / n) },
+			func(bin int) float64 {
+				if bin == 3 || bin == n-3 {
+					return n / 2
+				}
+				return 0
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			x := make([]complex128, n)
+			for i := range x {
+				x[i] = complex(tc.input(i), 0)
+			}
+			fft(x)
+			for bin, v := range x {
+				assert.InDelta(t, tc.expected(bin), cmplx.Abs(v), 1e-9, "bin %d", bin)
+			}
+		})
+	}
+}
@@ -1,6 +1,12 @@
 package audio
 
-import "errors"
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"player/logger"
+)
 
 var ErrNoOutput = errors.New("no output stream setup")
 
@@ -11,6 +17,305 @@ const (
 	INPUT_BUFFER_SIZE = 1
 )
 
+// Writes samples to an output, samples are interleaved float32
+// in the range -1 to 1
 type Writer interface {
-	Write([]int16) (int, error)
+	Write([]float32) (int, error)
+}
+
+// Global output and mixer, created when the output is opened
+var (
+	outputLock sync.RWMutex
+	output     Output
+	backend    string // Name of the backend output was opened on
+	device     string // Device output was opened on, empty for the default
+	mixer      *Mixer
+)
+
+// Output status changes, sent when an output fails and when it has
+// been reopened
+type OutputStatus struct {
+	Backend string
+	Device  string
+	Err     error         // Why the output failed, nil once reopened
+	Retry   time.Duration // Delay before the next attempt to reopen
+}
+
+// Output failure recovery
+var (
+	statusC   = make(chan OutputStatus, 8)
+	recoverC  chan bool // Closed to stop recovery, guarded by the output lock
+	recoverWg = &sync.WaitGroup{}
+)
+
+// Output status changes
+func Status() <-chan OutputStatus {
+	return (<-chan OutputStatus)(statusC)
+}
+
+// Sends a status change without blocking if nothing is listening
+func reportStatus(s OutputStatus) {
+	select {
+	case statusC <- s:
+	default:
+	}
+}
+
+// Reopens the output when the mixer reports it has failed until
+// closeC is closed
+func supervise(m *Mixer, c Configurer, closeC chan bool) {
+	defer recoverWg.Done()
+	for {
+		select {
+		case <-closeC:
+			return
+		case err := <-m.Failed():
+			if !reopen(err, c, closeC) {
+				return
+			}
+		}
+	}
+}
+
+// Reopens the current backend and device, backing off between attempts,
+// returns false if audio was closed before the output was reopened
+func reopen(cause error, c Configurer, closeC chan bool) bool {
+	backoff := c.RecoveryBackoff()
+	for {
+		name, dev := Current()
+		logger.WithError(cause).WithFields(logger.F{
+			"backend": name,
+			"device":  dev,
+			"retry":   backoff,
+		}).Warn("audio output failed")
+		reportStatus(OutputStatus{name, dev, cause, backoff})
+		select {
+		case <-closeC:
+			return false
+		case <-time.After(backoff):
+		}
+		old, err := swapOutput(name, dev)
+		if err == nil {
+			// A failed device may not close cleanly, don't hold up playback
+			go func() {
+				if err := old.Close(); err != nil {
+					logger.WithError(err).Warn("error closing failed audio output")
+				}
+			}()
+			reportStatus(OutputStatus{Backend: name, Device: dev})
+			return true
+		}
+		cause = err
+		if backoff *= 2; backoff > c.RecoveryMax() {
+			backoff = c.RecoveryMax()
+		}
+	}
+}
+
+// Opens the configured backend, falling back to the configured fallback
+// backends in order if it fails to open
+func Open() error {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	config := NewConfig()
+	names := append([]string{config.Backend()}, config.Fallback()...)
+	tried := make(map[string]bool)
+	for _, name := range names {
+		if tried[name] {
+			continue
+		}
+		tried[name] = true
+		f := logger.F{"backend": name}
+		b, err := GetBackend(name)
+		if err != nil {
+			logger.WithFields(f).WithError(err).Warn("audio backend not available")
+			continue
+		}
+		out, err := b.Open(config)
+		if err != nil {
+			logger.WithFields(f).WithError(err).Warn("unable to open audio backend")
+			continue
+		}
+		logger.WithFields(f).Info("opened audio backend")
+		output = out
+		backend = name
+		device = config.Device()
+		// Mix track and announcement inputs into the output
+		mixer = NewMixer(output, config)
+		mixer.Start()
+		// Reopen the output if it fails
+		recoverC = make(chan bool)
+		recoverWg.Add(1)
+		go supervise(mixer, config, recoverC)
+		return nil
+	}
+	return ErrNoBackend
+}
+
+// Opens a new output on a backend and device and switches the mixer to
+// it, closing the previous output. An empty backend reuses the current
+// backend, an empty device selects the default device.
+func SetOutput(name, dev string) error {
+	old, err := swapOutput(name, dev)
+	if err != nil {
+		return err
+	}
+	return old.Close()
+}
+
+// Opens a new output and switches the mixer to it, returning the
+// previous output. The lock is not held while the output opens or the
+// mixer writes so a hung output can be switched away from, the caller
+// closes the previous output to end any pending write.
+func swapOutput(name, dev string) (Output, error) {
+	outputLock.Lock()
+	m := mixer
+	if name == "" {
+		name = backend
+	}
+	outputLock.Unlock()
+	if m == nil {
+		return nil, ErrNoOutput
+	}
+	b, err := GetBackend(name)
+	if err != nil {
+		return nil, err
+	}
+	out, err := b.Open(deviceConfig{NewConfig(), dev})
+	if err != nil {
+		return nil, err
+	}
+	logger.WithFields(logger.F{
+		"backend": name,
+		"device":  dev,
+	}).Info("switched audio output")
+	outputLock.Lock()
+	old := m.SetOutput(out).(Output)
+	output, backend, device = out, name, dev
+	outputLock.Unlock()
+	return old, nil
+}
+
+// Returns the backend and device of the current output
+func Current() (string, string) {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	return backend, device
+}
+
+// Closes the mixer and audio output
+func Close() error {
+	logger.Debug("close audio")
+	defer logger.Debug("closed audio")
+	outputLock.Lock()
+	closeC := recoverC
+	recoverC = nil
+	outputLock.Unlock()
+	if closeC != nil {
+		close(closeC)
+		recoverWg.Wait()
+	}
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	if mixer != nil {
+		m := mixer
+		mixer = nil
+		if err := m.Close(); err != nil {
+			return err
+		}
+	}
+	if output != nil {
+		out := output
+		output = nil
+		return out.Close()
+	}
+	return nil
+}
+
+// Returs the current audio output
+func Get() (Output, error) {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	if output == nil {
+		return nil, ErrNoOutput
+	}
+	return output, nil
+}
+
+// Implemented by outputs which can send now playing metadata
+type Metadataer interface {
+	SetMetadata(title string)
+}
+
+// Sets the now playing title on the output if it supports metadata,
+// an empty title clears it
+func SetMetadata(title string) {
+	outputLock.Lock()
+	out := output
+	outputLock.Unlock()
+	if m, ok := out.(Metadataer); ok {
+		m.SetMetadata(title)
+	}
+}
+
+// Returns how long samples written now take to be heard, the output
+// latency plus the configured offset
+func Latency() time.Duration {
+	outputLock.Lock()
+	out := output
+	outputLock.Unlock()
+	latency := NewConfig().Offset()
+	if l, ok := out.(Latencyer); ok {
+		latency += l.Latency()
+	}
+	return latency
+}
+
+// Sets the main input resampling ratio, used to correct clock drift
+func SetRate(ratio float64) {
+	outputLock.RLock()
+	defer outputLock.RUnlock()
+	if mixer != nil {
+		mixer.Resampler().SetRatio(ratio)
+	}
+}
+
+// Returns how much of the main input has been played since the
+// position was last reset
+func Position() time.Duration {
+	outputLock.RLock()
+	defer outputLock.RUnlock()
+	if mixer == nil {
+		return 0
+	}
+	return time.Duration(mixer.Resampler().Frames()) * time.Second / SAMPLE_RATE
+}
+
+// Resets the main input position, e.g: when a track starts
+func ResetPosition() {
+	outputLock.RLock()
+	defer outputLock.RUnlock()
+	if mixer != nil {
+		mixer.Resampler().Reset()
+	}
+}
+
+// Returns the main track input of the output mixer
+func Main() (Writer, error) {
+	outputLock.RLock()
+	defer outputLock.RUnlock()
+	if mixer == nil {
+		return nil, ErrNoOutput
+	}
+	return mixer.Main(), nil
+}
+
+// Returns the announcement overlay input of the output mixer
+func Overlay() (Writer, error) {
+	outputLock.RLock()
+	defer outputLock.RUnlock()
+	if mixer == nil {
+		return nil, ErrNoOutput
+	}
+	return mixer.Overlay(), nil
 }
@@ -0,0 +1,28 @@
+package audio
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseTwice(t *testing.T) {
+	viper.Set("audio.backend", "null")
+	viper.Set("audio.fallback", []string{})
+	assert.NoError(t, Open())
+	done := make(chan bool)
+	go func() {
+		// Read while the output is closed
+		for i := 0; i < 100; i++ {
+			Main()
+			Position()
+		}
+		close(done)
+	}()
+	assert.NoError(t, Close())
+	assert.NoError(t, Close())
+	<-done
+	_, err := Main()
+	assert.Equal(t, ErrNoOutput, err)
+}
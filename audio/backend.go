@@ -0,0 +1,125 @@
+// Audio output backends
+//
+// Backends register themselves by name when compiled in, the backend
+// used is selected at runtime from config, falling back to others in
+// order if the preferred backend fails to open.
+
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoBackend      = errors.New("no audio backend could be opened")
+	ErrUnknownBackend = errors.New("unknown audio backend")
+	ErrNoDevices      = errors.New("audio backend does not list devices")
+	ErrUnknownDevice  = errors.New("unknown audio device")
+)
+
+// An opened audio output
+type Output interface {
+	Writer
+	Close() error
+}
+
+// Implemented by outputs which know how long written samples take to
+// be heard
+type Latencyer interface {
+	Latency() time.Duration
+}
+
+// Opens outputs on an audio system
+type Backend interface {
+	Open(c Configurer) (Output, error)
+}
+
+// An output device on a backend
+type Device struct {
+	Index   int    `json:"index"`
+	Name    string `json:"name"`
+	Default bool   `json:"default"`
+}
+
+// Implemented by backends which can list their output devices
+type DeviceLister interface {
+	Devices() ([]Device, error)
+}
+
+// Returns the output devices of a backend
+func Devices(name string) ([]Device, error) {
+	backend, err := GetBackend(name)
+	if err != nil {
+		return nil, err
+	}
+	lister, ok := backend.(DeviceLister)
+	if !ok {
+		return nil, ErrNoDevices
+	}
+	return lister.Devices()
+}
+
+// Finds a device by name or index, an empty device finds the default
+func FindDevice(devices []Device, device string) (Device, error) {
+	index, err := strconv.Atoi(device)
+	for _, d := range devices {
+		switch {
+		case device == "" && d.Default,
+			device != "" && d.Name == device,
+			err == nil && d.Index == index:
+			return d, nil
+		}
+	}
+	return Device{}, fmt.Errorf("%s: %s", ErrUnknownDevice, device)
+}
+
+// Overrides the configured device
+type deviceConfig struct {
+	Configurer
+	device string
+}
+
+func (c deviceConfig) Device() string {
+	return c.device
+}
+
+// Registered backends
+var (
+	backendsLock sync.RWMutex
+	backends     = make(map[string]Backend)
+)
+
+// Registers a backend by name, typically called from the backend's init
+func Register(name string, b Backend) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+	backends[name] = b
+}
+
+// Returns the names of the registered backends
+func Backends() []string {
+	backendsLock.RLock()
+	defer backendsLock.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns a registered backend by name
+func GetBackend(name string) (Backend, error) {
+	backendsLock.RLock()
+	defer backendsLock.RUnlock()
+	b, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %s", ErrUnknownBackend, name)
+	}
+	return b, nil
+}
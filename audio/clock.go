@@ -0,0 +1,21 @@
+package audio
+
+import "time"
+
+// Paces writes to outputs with no audio device so that samples are
+// consumed in real time
+type Clock struct {
+	next time.Time // When the previously written samples finish playing
+}
+
+// Blocks until the previously written samples have been played, then
+// accounts for n more samples. Time spent idle between writes is not
+// caught up on.
+func (c *Clock) Wait(n int) {
+	now := time.Now()
+	if c.next.Before(now) {
+		c.next = now
+	}
+	time.Sleep(c.next.Sub(now))
+	c.next = c.next.Add(samplesDuration(n))
+}
@@ -0,0 +1,397 @@
+package audio
+
+import (
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+const (
+	vSilenceThreshold = "audio.silence.threshold"
+	vSilenceTrailing  = "audio.silence.trailing"
+	vSilenceDeadAir   = "audio.silence.deadair"
+	vAnalyzerEnabled  = "audio.analyzer.enabled"
+	vAnalyzerRate     = "audio.analyzer.rate"
+	vAnalyzerBands    = "audio.analyzer.bands"
+	vDuckGain         = "audio.duck.gain"
+	vDuckRamp         = "audio.duck.ramp"
+	vFormat           = "audio.format"
+	vDither           = "audio.dither"
+	vBackend          = "audio.backend"
+	vFallback         = "audio.fallback"
+	vFilePath         = "audio.file.path"
+	vFileType         = "audio.file.type"
+	vFileRealtime     = "audio.file.realtime"
+	vPipeCommand      = "audio.pipe.command"
+	vPipeArgs         = "audio.pipe.args"
+	vPipeFormat       = "audio.pipe.format"
+	vPipeRestart      = "audio.pipe.restart"
+	vHTTPAddress      = "audio.http.address"
+	vHTTPPath         = "audio.http.path"
+	vHTTPCodec        = "audio.http.codec"
+	vHTTPEncoder      = "audio.http.encoder"
+	vHTTPBitrate      = "audio.http.bitrate"
+	vHTTPBuffer       = "audio.http.buffer"
+	vHTTPName         = "audio.http.name"
+	vTeeBranches      = "audio.tee.branch"
+	vDevice           = "audio.device"
+	vPulseName        = "audio.pulse.name"
+	vRecoveryBackoff  = "audio.recovery.backoff"
+	vRecoveryMax      = "audio.recovery.max"
+	vRTPAddress       = "audio.rtp.address"
+	vRTPCodec         = "audio.rtp.codec"
+	vRTPEncoder       = "audio.rtp.encoder"
+	vRTPBitrate       = "audio.rtp.bitrate"
+	vRTPJitter        = "audio.rtp.jitter"
+	vOffset           = "audio.offset"
+	vPipeTimeout      = "audio.pipe.timeout"
+)
+
+func init() {
+	viper.SetDefault(vSilenceThreshold, -60.0)
+	viper.SetDefault(vSilenceTrailing, "5s")
+	viper.SetDefault(vSilenceDeadAir, "10s")
+	viper.SetDefault(vAnalyzerEnabled, false)
+	viper.SetDefault(vAnalyzerRate, 20)
+	viper.SetDefault(vAnalyzerBands, 16)
+	viper.SetDefault(vDuckGain, -12.0)
+	viper.SetDefault(vDuckRamp, "250ms")
+	viper.SetDefault(vFormat, "s16")
+	viper.SetDefault(vDither, true)
+	viper.SetDefault(vBackend, "pulse")
+	viper.SetDefault(vFallback, []string{"portaudio"})
+	viper.SetDefault(vFilePath, "sfmplayer.wav")
+	viper.SetDefault(vFileType, "wav")
+	viper.SetDefault(vFileRealtime, true)
+	viper.SetDefault(vPipeCommand, "aplay")
+	viper.SetDefault(vPipeArgs, []string{"-q", "-t", "raw", "-f", "S16_LE", "-c", "2", "-r", "44100"})
+	viper.SetDefault(vPipeFormat, "s16")
+	viper.SetDefault(vPipeRestart, "1s")
+	viper.SetDefault(vHTTPAddress, ":8766")
+	viper.SetDefault(vHTTPPath, "/stream")
+	viper.SetDefault(vHTTPCodec, "wav")
+	viper.SetDefault(vHTTPEncoder, "ffmpeg")
+	viper.SetDefault(vHTTPBitrate, "128k")
+	viper.SetDefault(vHTTPBuffer, 64)
+	viper.SetDefault(vHTTPName, "SOON_ FM")
+	viper.SetDefault(vDevice, "")
+	viper.SetDefault(vPulseName, "SOON_ FM")
+	viper.SetDefault(vRecoveryBackoff, "1s")
+	viper.SetDefault(vRecoveryMax, "30s")
+	viper.SetDefault(vRTPAddress, "239.255.77.77:5004")
+	viper.SetDefault(vRTPCodec, "l16")
+	viper.SetDefault(vRTPEncoder, "ffmpeg")
+	viper.SetDefault(vRTPBitrate, "128k")
+	viper.SetDefault(vRTPJitter, "60ms")
+	viper.SetDefault(vOffset, "0s")
+	viper.SetDefault(vPipeTimeout, "2s")
+	viper.BindEnv(
+		vSilenceThreshold,
+		vSilenceTrailing,
+		vSilenceDeadAir,
+		vAnalyzerEnabled,
+		vAnalyzerRate,
+		vAnalyzerBands,
+		vDuckGain,
+		vDuckRamp,
+		vFormat,
+		vDither,
+		vBackend,
+		vFallback,
+		vFilePath,
+		vFileType,
+		vFileRealtime,
+		vPipeCommand,
+		vPipeArgs,
+		vPipeFormat,
+		vPipeRestart,
+		vHTTPAddress,
+		vHTTPPath,
+		vHTTPCodec,
+		vHTTPEncoder,
+		vHTTPBitrate,
+		vHTTPBuffer,
+		vHTTPName,
+		vDevice,
+		vPulseName,
+		vRecoveryBackoff,
+		vRecoveryMax,
+		vRTPAddress,
+		vRTPCodec,
+		vRTPEncoder,
+		vRTPBitrate,
+		vRTPJitter,
+		vOffset,
+		vPipeTimeout)
+}
+
+// Audio configuration interface
+type Configurer interface {
+	Backend() string
+	Fallback() []string
+	Format() string
+	Dither() bool
+	SilenceThreshold() float64
+	SilenceTrailing() time.Duration
+	SilenceDeadAir() time.Duration
+	FilePath() string
+	FileType() string
+	FileRealtime() bool
+	PipeCommand() string
+	PipeArgs() []string
+	PipeFormat() string
+	PipeRestart() time.Duration
+	HTTPAddress() string
+	HTTPPath() string
+	HTTPCodec() string
+	HTTPEncoder() string
+	HTTPBitrate() string
+	HTTPBuffer() int
+	HTTPName() string
+	TeeBranches() ([]TeeBranch, error)
+	Device() string
+	PulseName() string
+	RecoveryBackoff() time.Duration
+	RecoveryMax() time.Duration
+	RTPAddress() string
+	RTPCodec() string
+	RTPEncoder() string
+	RTPBitrate() string
+	RTPJitter() time.Duration
+	Offset() time.Duration
+	PipeTimeout() time.Duration
+}
+
+// Analyzer configuration interface
+type AnalyzerConfigurer interface {
+	AnalyzerEnabled() bool
+	AnalyzerRate() int
+	AnalyzerBands() int
+}
+
+// A tee backend output branch
+type TeeBranch struct {
+	Backend string        `mapstructure:"backend"` // Backend name, e.g: pulse
+	Volume  float64       `mapstructure:"volume"`  // Gain in dB applied to the branch
+	Delay   time.Duration `mapstructure:"delay"`   // Delay to align with slower branches
+	Queue   int           `mapstructure:"queue"`   // Buffers queued before dropping
+}
+
+// Ducking configuration interface
+type DuckConfigurer interface {
+	DuckGain() float64
+	DuckRamp() time.Duration
+}
+
+type Config struct{}
+
+// Name of the preferred output backend
+func (c Config) Backend() string {
+	return viper.GetString(vBackend)
+}
+
+// Backends to try in order if the preferred backend fails to open
+func (c Config) Fallback() []string {
+	return viper.GetStringSlice(vFallback)
+}
+
+// Output sample format, s16, s24 or f32
+func (c Config) Format() string {
+	return viper.GetString(vFormat)
+}
+
+// Apply TPDF dither when converting to an integer sample format
+func (c Config) Dither() bool {
+	return viper.GetBool(vDither)
+}
+
+// Level in dBFS below which samples are considered silent
+func (c Config) SilenceThreshold() float64 {
+	return viper.GetFloat64(vSilenceThreshold)
+}
+
+// Sustained silence after which a track is considered finished,
+// 0 disables trailing silence detection
+func (c Config) SilenceTrailing() time.Duration {
+	return viper.GetDuration(vSilenceTrailing)
+}
+
+// Sustained silence or stalled input after which dead air is
+// reported, 0 disables dead air detection
+func (c Config) SilenceDeadAir() time.Duration {
+	return viper.GetDuration(vSilenceDeadAir)
+}
+
+// Enables level and spectrum analysis
+func (c Config) AnalyzerEnabled() bool {
+	return viper.GetBool(vAnalyzerEnabled)
+}
+
+// Number of level updates published per second
+func (c Config) AnalyzerRate() int {
+	return viper.GetInt(vAnalyzerRate)
+}
+
+// Number of spectrum bands
+func (c Config) AnalyzerBands() int {
+	return viper.GetInt(vAnalyzerBands)
+}
+
+// Gain in dB applied to the main track while an announcement plays
+func (c Config) DuckGain() float64 {
+	return viper.GetFloat64(vDuckGain)
+}
+
+// Time taken to duck and restore the main track
+func (c Config) DuckRamp() time.Duration {
+	return viper.GetDuration(vDuckRamp)
+}
+
+// Path of the file backend recording
+func (c Config) FilePath() string {
+	return viper.GetString(vFilePath)
+}
+
+// File backend container, wav or raw
+func (c Config) FileType() string {
+	return viper.GetString(vFileType)
+}
+
+// Pace file backend writes in real time
+func (c Config) FileRealtime() bool {
+	return viper.GetBool(vFileRealtime)
+}
+
+// Command the pipe backend writes samples to
+func (c Config) PipeCommand() string {
+	return viper.GetString(vPipeCommand)
+}
+
+// Arguments for the pipe backend command
+func (c Config) PipeArgs() []string {
+	return viper.GetStringSlice(vPipeArgs)
+}
+
+// Sample format written to the pipe backend command, s16, s24 or f32
+func (c Config) PipeFormat() string {
+	return viper.GetString(vPipeFormat)
+}
+
+// Delay before restarting the pipe backend command if it exits
+func (c Config) PipeRestart() time.Duration {
+	return viper.GetDuration(vPipeRestart)
+}
+
+// Address the http stream backend listens on
+func (c Config) HTTPAddress() string {
+	return viper.GetString(vHTTPAddress)
+}
+
+// Path the http stream is served from
+func (c Config) HTTPPath() string {
+	return viper.GetString(vHTTPPath)
+}
+
+// Http stream codec, wav, mp3 or opus
+func (c Config) HTTPCodec() string {
+	return viper.GetString(vHTTPCodec)
+}
+
+// Encoder command used for mp3 and opus http streams
+func (c Config) HTTPEncoder() string {
+	return viper.GetString(vHTTPEncoder)
+}
+
+// Bitrate of mp3 and opus http streams
+func (c Config) HTTPBitrate() string {
+	return viper.GetString(vHTTPBitrate)
+}
+
+// Number of chunks buffered per listener before it is dropped
+func (c Config) HTTPBuffer() int {
+	return viper.GetInt(vHTTPBuffer)
+}
+
+// Station name sent to http stream listeners
+func (c Config) HTTPName() string {
+	return viper.GetString(vHTTPName)
+}
+
+// Outputs the tee backend writes to, the first branch paces playback
+func (c Config) TeeBranches() ([]TeeBranch, error) {
+	var branches []TeeBranch
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           &branches,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(viper.Get(vTeeBranches)); err != nil {
+		return nil, err
+	}
+	return branches, nil
+}
+
+// Output device name or index, empty for the default device
+func (c Config) Device() string {
+	return viper.GetString(vDevice)
+}
+
+// Client and stream name of the pulse audio stream
+func (c Config) PulseName() string {
+	return viper.GetString(vPulseName)
+}
+
+// Delay before the first attempt to reopen a failed output
+func (c Config) RecoveryBackoff() time.Duration {
+	return viper.GetDuration(vRecoveryBackoff)
+}
+
+// Longest delay between attempts to reopen a failed output
+func (c Config) RecoveryMax() time.Duration {
+	return viper.GetDuration(vRecoveryMax)
+}
+
+// Multicast or unicast address rtp audio is sent to and received on
+func (c Config) RTPAddress() string {
+	return viper.GetString(vRTPAddress)
+}
+
+// Rtp payload codec, l16 or opus
+func (c Config) RTPCodec() string {
+	return viper.GetString(vRTPCodec)
+}
+
+// Command used to encode and decode opus rtp streams
+func (c Config) RTPEncoder() string {
+	return viper.GetString(vRTPEncoder)
+}
+
+// Bitrate of opus rtp streams
+func (c Config) RTPBitrate() string {
+	return viper.GetString(vRTPBitrate)
+}
+
+// Audio held by the rtp receiver to absorb network jitter
+func (c Config) RTPJitter() time.Duration {
+	return viper.GetDuration(vRTPJitter)
+}
+
+// Extra delay added to the measured output latency, e.g: for
+// bluetooth speakers
+func (c Config) Offset() time.Duration {
+	return viper.GetDuration(vOffset)
+}
+
+// Time a write to the pipe backend command may stall before the command
+// is killed and restarted, 0 disables
+func (c Config) PipeTimeout() time.Duration {
+	return viper.GetDuration(vPipeTimeout)
+}
+
+func NewConfig() Config {
+	return Config{}
+}
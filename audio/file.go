@@ -0,0 +1,217 @@
+// File output, records everything played to a WAV or raw PCM file
+
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"player/logger"
+)
+
+var (
+	ErrUnknownFileType = errors.New("unknown file type, must be wav or raw")
+	ErrFileFormat      = errors.New("existing recording is not a wav file in the configured format")
+)
+
+// WAV format tags
+const (
+	wavPCM   = 1
+	wavFloat = 3
+)
+
+// Size of the canonical WAV header
+const wavHeaderSize = 44
+
+// Audio written between WAV header updates, so a recording which is not
+// closed cleanly is still readable
+const wavHeaderInterval = time.Second * 5
+
+func init() {
+	Register("file", File{})
+}
+
+// File output backend
+type File struct{}
+
+// Opens the recording file, appending to an existing recording
+func (File) Open(c Configurer) (Output, error) {
+	format, err := ParseFormat(c.Format())
+	if err != nil {
+		return nil, err
+	}
+	wav := false
+	switch c.FileType() {
+	case "wav":
+		wav = true
+	case "raw":
+	default:
+		return nil, ErrUnknownFileType
+	}
+	f, err := os.OpenFile(c.FilePath(), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	logger.WithFields(logger.F{
+		"path":   c.FilePath(),
+		"type":   c.FileType(),
+		"format": format,
+	}).Debug("recording audio output to file")
+	output := NewFileOutput(f, format, NewQuantizer(c.Dither()), wav, c.FileRealtime())
+	if err := output.resume(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return output, nil
+}
+
+// Writes samples to a file
+type FileOutput struct {
+	file      *os.File
+	writer    *bufio.Writer
+	format    Format
+	quantizer *Quantizer
+	wav       bool   // Write a WAV header
+	written   uint32 // Bytes of sample data written
+	synced    uint32 // Bytes of sample data in the WAV header
+	realtime  bool   // Pace writes with the clock
+	clock     Clock
+}
+
+// Continues an existing recording after the last whole frame of its
+// sample data, rewriting the WAV header for the data already recorded
+func (output *FileOutput) resume() error {
+	info, err := output.file.Stat()
+	if err != nil {
+		return err
+	}
+	start := int64(0)
+	if output.wav {
+		start = wavHeaderSize
+		if info.Size() >= wavHeaderSize {
+			if err := output.checkHeader(); err != nil {
+				return err
+			}
+		}
+	}
+	data := info.Size() - start
+	if data < 0 {
+		data = 0
+	}
+	data -= data % int64(output.format.Size()*CHANNELS)
+	if _, err := output.file.Seek(start+data, io.SeekStart); err != nil {
+		return err
+	}
+	output.written = uint32(data)
+	if output.wav {
+		return output.header()
+	}
+	return nil
+}
+
+// Returns ErrFileFormat unless the file starts with a WAV header for the
+// output format
+func (output *FileOutput) checkHeader() error {
+	h := make([]byte, wavHeaderSize)
+	if _, err := output.file.ReadAt(h, 0); err != nil {
+		return err
+	}
+	size := uint16(output.format.Size())
+	switch {
+	case string(h[0:4]) != "RIFF", string(h[8:12]) != "WAVE", string(h[36:40]) != "data":
+		return ErrFileFormat
+	case binary.LittleEndian.Uint16(h[20:]) != output.tag():
+		return ErrFileFormat
+	case binary.LittleEndian.Uint16(h[22:]) != CHANNELS:
+		return ErrFileFormat
+	case binary.LittleEndian.Uint16(h[34:]) != size*8:
+		return ErrFileFormat
+	}
+	return nil
+}
+
+// Returns the WAV format tag of the output format
+func (output *FileOutput) tag() uint16 {
+	if output.format == F32 {
+		return wavFloat
+	}
+	return wavPCM
+}
+
+// Writes the WAV header for the sample data written so far
+func (output *FileOutput) header() error {
+	size := uint16(output.format.Size())
+	h := make([]byte, wavHeaderSize)
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], wavHeaderSize-8+output.written)
+	copy(h[8:], "WAVE")
+	copy(h[12:], "fmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], output.tag())
+	binary.LittleEndian.PutUint16(h[22:], CHANNELS)
+	binary.LittleEndian.PutUint32(h[24:], SAMPLE_RATE)
+	binary.LittleEndian.PutUint32(h[28:], SAMPLE_RATE*CHANNELS*uint32(size))
+	binary.LittleEndian.PutUint16(h[32:], CHANNELS*size)
+	binary.LittleEndian.PutUint16(h[34:], size*8)
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], output.written)
+	if _, err := output.file.WriteAt(h, 0); err != nil {
+		return err
+	}
+	output.synced = output.written
+	return nil
+}
+
+// Encodes and writes samples to the file
+func (output *FileOutput) Write(data []float32) (int, error) {
+	if output.realtime {
+		output.clock.Wait(len(data))
+	}
+	n, err := output.writer.Write(output.quantizer.Encode(output.format, data))
+	output.written += uint32(n)
+	if err != nil {
+		return 0, err
+	}
+	interval := uint32(wavHeaderInterval.Seconds()*SAMPLE_RATE) * CHANNELS * uint32(output.format.Size())
+	if output.wav && output.written-output.synced >= interval {
+		if err := output.writer.Flush(); err != nil {
+			return 0, err
+		}
+		if err := output.header(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// Flushes the recording, completes the WAV header and closes the file
+func (output *FileOutput) Close() error {
+	err := output.writer.Flush()
+	if err == nil && output.wav {
+		err = output.header()
+	}
+	if cerr := output.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Construct a new file output, space for the WAV header is reserved
+// at the start of the file when wav is true
+func NewFileOutput(f *os.File, format Format, q *Quantizer, wav, realtime bool) *FileOutput {
+	if wav {
+		f.Seek(wavHeaderSize, io.SeekStart)
+	}
+	return &FileOutput{
+		file:      f,
+		writer:    bufio.NewWriter(f),
+		format:    format,
+		quantizer: q,
+		wav:       wav,
+		realtime:  realtime,
+	}
+}
@@ -0,0 +1,91 @@
+package audio
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileOutputWAV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sfmplayer")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.wav")
+	tt := []struct {
+		tname  string
+		format Format
+		tag    uint16
+	}{
+		{"s16", S16, wavPCM},
+		{"s24", S24, wavPCM},
+		{"f32", F32, wavFloat},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			f, err := os.Create(path)
+			assert.NoError(t, err)
+			output := NewFileOutput(f, tc.format, NewQuantizer(false), true, false)
+			assert.NoError(t, output.header())
+			n, err := output.Write([]float32{0, 0.5, -0.5, 1})
+			assert.NoError(t, err)
+			assert.Equal(t, 4, n)
+			assert.NoError(t, output.Close())
+			b, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			size := uint32(4 * tc.format.Size())
+			assert.Equal(t, wavHeaderSize+int(size), len(b))
+			assert.Equal(t, "RIFF", string(b[0:4]))
+			assert.Equal(t, wavHeaderSize-8+size, binary.LittleEndian.Uint32(b[4:]))
+			assert.Equal(t, tc.tag, binary.LittleEndian.Uint16(b[20:]))
+			assert.Equal(t, uint16(tc.format.Size()*8), binary.LittleEndian.Uint16(b[34:]))
+			assert.Equal(t, "data", string(b[36:40]))
+			assert.Equal(t, size, binary.LittleEndian.Uint32(b[40:]))
+		})
+	}
+}
+
+func TestFileOutputResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sfmplayer")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "out.wav")
+	// Opens the recording and writes a frame to it without closing it
+	record := func(format Format) (*FileOutput, error) {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		output := NewFileOutput(f, format, NewQuantizer(false), true, false)
+		if err := output.resume(); err != nil {
+			f.Close()
+			return nil, err
+		}
+		_, err = output.Write([]float32{0.5, -0.5})
+		return output, err
+	}
+	output, err := record(S16)
+	assert.NoError(t, err)
+	assert.NoError(t, output.Close())
+	// Not closed, as if the player crashed
+	output, err = record(S16)
+	assert.NoError(t, err)
+	assert.NoError(t, output.writer.Flush())
+	assert.NoError(t, output.file.Close())
+	// Reopening completes the header for everything recorded
+	output, err = record(S16)
+	assert.NoError(t, err)
+	assert.NoError(t, output.Close())
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	size := uint32(3 * 2 * S16.Size())
+	assert.Equal(t, wavHeaderSize+int(size), len(b))
+	assert.Equal(t, wavHeaderSize-8+size, binary.LittleEndian.Uint32(b[4:]))
+	assert.Equal(t, size, binary.LittleEndian.Uint32(b[40:]))
+	// A recording in another format is not appended to
+	_, err = record(F32)
+	assert.Equal(t, ErrFileFormat, err)
+}
@@ -0,0 +1,109 @@
+// Conversion from the internal float32 samples to output sample formats
+
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Output sample format
+type Format string
+
+// Supported output sample formats
+const (
+	S16 Format = "s16" // Signed 16 bit little endian
+	S24 Format = "s24" // Signed 24 bit packed little endian
+	F32 Format = "f32" // 32 bit float little endian
+)
+
+var ErrUnknownFormat = errors.New("unknown sample format, must be s16, s24 or f32")
+
+// Parses a sample format name
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case S16, S24, F32:
+		return f, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// Returns the size of a single sample in bytes
+func (f Format) Size() int {
+	switch f {
+	case S24:
+		return 3
+	case F32:
+		return 4
+	}
+	return 2
+}
+
+// Clamps a sample to the -1 to 1 range
+func clamp(s float32) float32 {
+	if s > 1 {
+		return 1
+	}
+	if s < -1 {
+		return -1
+	}
+	return s
+}
+
+// Converts float32 samples to an integer output format, applying
+// triangular (TPDF) dither when reducing bit depth, samples already at
+// the output bit depth, such as an untouched 16 bit source, are exact
+type Quantizer struct {
+	dither bool
+	rand   *rand.Rand
+}
+
+// Returns a sample scaled to a signed integer of the given bit depth
+// with dither applied unless it is already exact
+func (q *Quantizer) quantize(s float32, bits uint) int32 {
+	scale := float64(int64(1) << (bits - 1))
+	v := float64(clamp(s)) * scale
+	if q.dither && v != math.Floor(v) {
+		// Sum of two uniform distributions gives a triangular
+		// distribution of +/- 1 least significant bit
+		v += q.rand.Float64() - q.rand.Float64()
+	}
+	v = math.Floor(v + 0.5)
+	return int32(math.Max(-scale, math.Min(scale-1, v)))
+}
+
+// Converts samples to signed 16 bit integers
+func (q *Quantizer) S16(dst []int16, src []float32) {
+	for i, s := range src {
+		dst[i] = int16(q.quantize(s, 16))
+	}
+}
+
+// Encodes samples as little endian bytes in the given format
+func (q *Quantizer) Encode(f Format, src []float32) []byte {
+	b := make([]byte, len(src)*f.Size())
+	for i, s := range src {
+		switch f {
+		case S16:
+			binary.LittleEndian.PutUint16(b[i*2:], uint16(q.quantize(s, 16)))
+		case S24:
+			v := q.quantize(s, 24)
+			b[i*3], b[i*3+1], b[i*3+2] = byte(v), byte(v>>8), byte(v>>16)
+		case F32:
+			binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(clamp(s)))
+		}
+	}
+	return b
+}
+
+// Constructs a new Quantizer
+func NewQuantizer(dither bool) *Quantizer {
+	return &Quantizer{
+		dither: dither,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
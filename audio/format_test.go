@@ -0,0 +1,84 @@
+package audio
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFormat(t *testing.T) {
+	tt := []struct {
+		name     string
+		expected Format
+		err      error
+	}{
+		{"s16", S16, nil},
+		{"S24", S24, nil},
+		{"f32", F32, nil},
+		{"u8", "", ErrUnknownFormat},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := ParseFormat(tc.name)
+			assert.Equal(t, tc.expected, f)
+			assert.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func TestQuantizerS16(t *testing.T) {
+	src := []float32{0, 0.5, -0.5, 1, -1, 2, -2}
+	dst := make([]int16, len(src))
+	NewQuantizer(false).S16(dst, src)
+	assert.Equal(t, []int16{0, 16384, -16384, math.MaxInt16, math.MinInt16, math.MaxInt16, math.MinInt16}, dst)
+	// Dither stays within a least significant bit of the exact value
+	NewQuantizer(true).S16(dst, src)
+	for i, s := range src {
+		exact := float64(clamp(s)) * (math.MaxInt16 + 1)
+		assert.InDelta(t, math.Min(exact, math.MaxInt16), float64(dst[i]), 1.5)
+	}
+}
+
+func TestQuantizerDither(t *testing.T) {
+	tt := []struct {
+		tname string
+		gain  float32
+		exact bool
+	}{
+		{"16 bit source", 1, true},
+		{"mixed 16 bit sources", 2, true},
+		{"gain applied", 0.3, false},
+	}
+	q := NewQuantizer(true)
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			var src []float32
+			for _, s := range []int16{1, -1, 1000, -12345, 16000} {
+				src = append(src, float32(s)/(math.MaxInt16+1)*tc.gain)
+			}
+			undithered := make([]int16, len(src))
+			NewQuantizer(false).S16(undithered, src)
+			// Dither varies inexact samples between conversions
+			varied := false
+			for i := 0; i < 100; i++ {
+				dst := make([]int16, len(src))
+				q.S16(dst, src)
+				if tc.exact {
+					assert.Equal(t, undithered, dst)
+				}
+				for j := range dst {
+					varied = varied || dst[j] != undithered[j]
+				}
+			}
+			assert.Equal(t, !tc.exact, varied)
+		})
+	}
+}
+
+func TestQuantizerEncode(t *testing.T) {
+	q := NewQuantizer(false)
+	assert.Equal(t, []byte{0x00, 0x40}, q.Encode(S16, []float32{0.5}))
+	assert.Equal(t, []byte{0x00, 0x00, 0x40}, q.Encode(S24, []float32{0.5}))
+	assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x3f}, q.Encode(F32, []float32{0.5}))
+}
@@ -0,0 +1,399 @@
+// HTTP stream output, serves the live mix to any number of listeners
+// with optional ICY now playing metadata
+
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"player/logger"
+)
+
+var (
+	ErrUnknownCodec = errors.New("unknown http stream codec, must be wav, mp3 or opus")
+	ErrNoEncoder    = errors.New("encoder not found")
+)
+
+// Bytes of audio sent between ICY metadata blocks
+const icyMetaInt = 16000
+
+// Http stream codecs
+var httpCodecs = map[string]struct {
+	contentType string
+	args        []string // Encoder arguments, nil if not encoded
+}{
+	"wav":  {"audio/wav", nil},
+	"mp3":  {"audio/mpeg", []string{"-c:a", "libmp3lame", "-f", "mp3"}},
+	"opus": {"audio/ogg", []string{"-c:a", "libopus", "-f", "ogg"}},
+}
+
+func init() {
+	Register("http", HTTP{})
+}
+
+// Http stream output backend
+type HTTP struct{}
+
+// Starts the http stream server and encoder
+func (HTTP) Open(c Configurer) (Output, error) {
+	codec, ok := httpCodecs[c.HTTPCodec()]
+	if !ok {
+		return nil, ErrUnknownCodec
+	}
+	encoder, err := exec.LookPath(c.HTTPEncoder())
+	if codec.args != nil && err != nil {
+		return nil, ErrNoEncoder
+	}
+	ln, err := net.Listen("tcp", c.HTTPAddress())
+	if err != nil {
+		return nil, err
+	}
+	output := NewHTTPOutput(c, codec.contentType, NewQuantizer(c.Dither()))
+	if codec.args != nil {
+		args := []string{
+			"-hide_banner", "-loglevel", "error",
+			"-f", "s16le", "-ar", strconv.Itoa(SAMPLE_RATE), "-ac", strconv.Itoa(CHANNELS), "-i", "-",
+			"-b:a", c.HTTPBitrate(),
+		}
+		args = append(append(args, codec.args...), "-")
+		r, w := io.Pipe()
+		output.encoder = NewPipeOutput(encoder, args, S16, output.quantizer, time.Second)
+		output.encoder.stdout = w
+		output.encoder.clock = nil // Paced by the http output
+		if err := output.encoder.start(); err != nil {
+			ln.Close()
+			return nil, err
+		}
+		if c.HTTPCodec() == "opus" {
+			go output.readOgg(r)
+		} else {
+			go output.read(r)
+		}
+	} else {
+		output.header = wavStreamHeader()
+	}
+	go output.Serve(ln)
+	return output, nil
+}
+
+// Returns a WAV header for a stream of unknown length
+func wavStreamHeader() []byte {
+	h := make([]byte, wavHeaderSize)
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], 0xffffffff)
+	copy(h[8:], "WAVE")
+	copy(h[12:], "fmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], wavPCM)
+	binary.LittleEndian.PutUint16(h[22:], CHANNELS)
+	binary.LittleEndian.PutUint32(h[24:], SAMPLE_RATE)
+	binary.LittleEndian.PutUint32(h[28:], SAMPLE_RATE*CHANNELS*2)
+	binary.LittleEndian.PutUint16(h[32:], CHANNELS*2)
+	binary.LittleEndian.PutUint16(h[34:], 16)
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], 0xffffffff)
+	return h
+}
+
+// Returns the escaped stream title, shortened on a character boundary to
+// fit a metadata block
+func icyTitle(title string) string {
+	max := 255*16 - len("StreamTitle='';")
+	escaped := make([]byte, 0, len(title))
+	for i := 0; i < len(title); {
+		_, size := utf8.DecodeRuneInString(title[i:])
+		c := title[i : i+size]
+		if c == "'" {
+			c = "\\'"
+		}
+		if len(escaped)+len(c) > max {
+			break
+		}
+		escaped = append(escaped, c...)
+		i += size
+	}
+	return string(escaped)
+}
+
+// Returns an ICY metadata block for a stream title
+func icyMetadata(title string) []byte {
+	if title == "" {
+		return []byte{0}
+	}
+	meta := fmt.Sprintf("StreamTitle='%s';", icyTitle(title))
+	n := (len(meta) + 15) / 16
+	b := make([]byte, 1+n*16)
+	b[0] = byte(n)
+	copy(b[1:], meta)
+	return b
+}
+
+// A connected listener
+type httpListener struct {
+	addr    string
+	C       chan []byte
+	metaint int    // Bytes between metadata blocks, 0 if not requested
+	count   int    // Bytes sent since the last metadata block
+	title   string // Last title sent
+}
+
+// Writes a chunk to the listener, inserting ICY metadata blocks
+func (l *httpListener) write(w io.Writer, b []byte, title string) error {
+	for len(b) > 0 {
+		n := len(b)
+		if l.metaint > 0 && l.count+n > l.metaint {
+			n = l.metaint - l.count
+		}
+		if _, err := w.Write(b[:n]); err != nil {
+			return err
+		}
+		b = b[n:]
+		l.count += n
+		if l.metaint > 0 && l.count == l.metaint {
+			meta := []byte{0}
+			if title != l.title {
+				meta, l.title = icyMetadata(title), title
+			}
+			if _, err := w.Write(meta); err != nil {
+				return err
+			}
+			l.count = 0
+		}
+	}
+	return nil
+}
+
+// Serves the live mix over http
+type HTTPOutput struct {
+	Config      Configurer
+	contentType string
+	quantizer   *Quantizer
+	encoder     *PipeOutput // Encodes to mp3 or opus, nil for wav
+	server      *http.Server
+	clock       Clock
+	// Listeners and stream state
+	lock      sync.RWMutex
+	listeners map[*httpListener]bool
+	header    []byte // Sent to listeners before any audio
+	title     string // ICY stream title
+	closed    bool
+}
+
+// Sets the ICY now playing title
+func (output *HTTPOutput) SetMetadata(title string) {
+	output.lock.Lock()
+	defer output.lock.Unlock()
+	output.title = title
+}
+
+// Sends a chunk to all listeners
+func (output *HTTPOutput) broadcast(b []byte) {
+	output.lock.Lock()
+	defer output.lock.Unlock()
+	output.send(b)
+}
+
+// Sends a chunk to all listeners, dropping listeners whose buffer is
+// full, the lock must be held
+func (output *HTTPOutput) send(b []byte) {
+	for l := range output.listeners {
+		select {
+		case l.C <- b:
+		default:
+			logger.WithField("addr", l.addr).Warn("dropping slow http stream listener")
+			delete(output.listeners, l)
+			close(l.C)
+		}
+	}
+}
+
+// Reads encoded output, broadcasting it to listeners
+func (output *HTTPOutput) read(r io.Reader) {
+	for {
+		b := make([]byte, 4096)
+		n, err := r.Read(b)
+		if n > 0 {
+			output.broadcast(b[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Reads ogg encoded output a page at a time so listeners join on a page
+// boundary, header pages are kept for listeners that join mid stream
+func (output *HTTPOutput) readOgg(r io.Reader) {
+	for {
+		page, err := readOggPage(r)
+		if err != nil {
+			return
+		}
+		granule := binary.LittleEndian.Uint64(page[6:])
+		output.lock.Lock()
+		if page[5]&0x02 != 0 { // Beginning of stream, encoder (re)started
+			output.header = nil
+		}
+		if granule == 0 {
+			output.header = append(output.header, page...)
+		}
+		output.send(page)
+		output.lock.Unlock()
+	}
+}
+
+// Reads a single ogg page
+func readOggPage(r io.Reader) ([]byte, error) {
+	header := make([]byte, 27)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != "OggS" {
+		return nil, errors.New("invalid ogg page")
+	}
+	segments := make([]byte, header[26])
+	if _, err := io.ReadFull(r, segments); err != nil {
+		return nil, err
+	}
+	size := 0
+	for _, s := range segments {
+		size += int(s)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	page := append(header, segments...)
+	return append(page, body...), nil
+}
+
+// Serves the stream to a listener until it disconnects or is dropped
+func (output *HTTPOutput) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l := &httpListener{
+		addr: r.RemoteAddr,
+		C:    make(chan []byte, output.Config.HTTPBuffer()),
+	}
+	w.Header().Set("Content-Type", output.contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("icy-name", output.Config.HTTPName())
+	if r.Header.Get("Icy-MetaData") == "1" {
+		l.metaint = icyMetaInt
+		w.Header().Set("icy-metaint", strconv.Itoa(icyMetaInt))
+	}
+	output.lock.Lock()
+	if output.closed {
+		output.lock.Unlock()
+		http.Error(w, "stream closed", http.StatusServiceUnavailable)
+		return
+	}
+	header := output.header
+	output.listeners[l] = true
+	output.lock.Unlock()
+	logger.WithField("addr", l.addr).Info("http stream listener connected")
+	defer logger.WithField("addr", l.addr).Info("http stream listener disconnected")
+	defer output.remove(l)
+	flusher, _ := w.(http.Flusher)
+	if err := l.write(w, header, ""); err != nil {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case b, ok := <-l.C:
+			if !ok {
+				return
+			}
+			output.lock.RLock()
+			title := output.title
+			output.lock.RUnlock()
+			if err := l.write(w, b, title); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// Removes a listener
+func (output *HTTPOutput) remove(l *httpListener) {
+	output.lock.Lock()
+	defer output.lock.Unlock()
+	if output.listeners[l] {
+		delete(output.listeners, l)
+		close(l.C)
+	}
+}
+
+// Serves the stream on a listener, blocks until closed
+func (output *HTTPOutput) Serve(ln net.Listener) error {
+	logger.WithField("address", ln.Addr()).Debug("start http stream server")
+	if err := output.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+		logger.WithError(err).Error("http stream server error")
+		return err
+	}
+	return nil
+}
+
+// Writes samples to the encoder or directly to listeners for wav,
+// writes are paced in real time
+func (output *HTTPOutput) Write(data []float32) (int, error) {
+	output.clock.Wait(len(data))
+	if output.encoder != nil {
+		return output.encoder.Write(data)
+	}
+	output.broadcast(output.quantizer.Encode(S16, data))
+	return len(data), nil
+}
+
+// Disconnects listeners and stops the server and encoder
+func (output *HTTPOutput) Close() error {
+	output.lock.Lock()
+	output.closed = true
+	for l := range output.listeners {
+		delete(output.listeners, l)
+		close(l.C)
+	}
+	output.lock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err := output.server.Shutdown(ctx); err != nil {
+		return err
+	}
+	if output.encoder != nil {
+		if err := output.encoder.Close(); err != nil {
+			return err
+		}
+		if w, ok := output.encoder.stdout.(io.Closer); ok {
+			w.Close()
+		}
+	}
+	return nil
+}
+
+// Construct a new http stream output
+func NewHTTPOutput(c Configurer, contentType string, q *Quantizer) *HTTPOutput {
+	output := &HTTPOutput{
+		Config:      c,
+		contentType: contentType,
+		quantizer:   q,
+		listeners:   make(map[*httpListener]bool),
+	}
+	mux := http.NewServeMux()
+	mux.Handle(c.HTTPPath(), output)
+	output.server = &http.Server{Handler: mux}
+	return output
+}
@@ -0,0 +1,130 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestICYMetadata(t *testing.T) {
+	tt := []struct {
+		tname  string
+		title  string
+		blocks int
+		meta   string
+	}{
+		{"no title", "", 0, ""},
+		{"title", "Artist - Title", 2, "StreamTitle='Artist - Title';"},
+		{"escaped", "Don't", 2, "StreamTitle='Don\\'t';"},
+		{"truncated", strings.Repeat("a", 5000), 255, "StreamTitle='" + strings.Repeat("a", 255*16-15) + "';"},
+		{"truncated escape", strings.Repeat("'", 5000), 255, "StreamTitle='" + strings.Repeat("\\'", (255*16-15)/2) + "';"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			b := icyMetadata(tc.title)
+			assert.Equal(t, tc.blocks, int(b[0]))
+			assert.Len(t, b, 1+tc.blocks*16)
+			assert.Equal(t, tc.meta, string(bytes.TrimRight(b[1:], "\x00")))
+		})
+	}
+}
+
+func TestHTTPListenerWrite(t *testing.T) {
+	tt := []struct {
+		tname    string
+		metaint  int
+		writes   []string
+		expected string
+	}{
+		{"no metadata", 0, []string{"abcdef", "gh"}, "abcdefgh"},
+		{"interleaved", 4, []string{"abcdef", "gh"}, "abcd" + string(icyMetadata("t")) + "efgh\x00"},
+		{"unchanged title", 2, []string{"abcd"}, "ab" + string(icyMetadata("t")) + "cd\x00"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			l := &httpListener{metaint: tc.metaint}
+			buf := &bytes.Buffer{}
+			for _, w := range tc.writes {
+				assert.NoError(t, l.write(buf, []byte(w), "t"))
+			}
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+}
+
+func TestHTTPOutputDropsSlowListener(t *testing.T) {
+	output := &HTTPOutput{listeners: make(map[*httpListener]bool)}
+	slow := &httpListener{C: make(chan []byte, 1)}
+	fast := &httpListener{C: make(chan []byte, 2)}
+	output.listeners[slow] = true
+	output.listeners[fast] = true
+	output.broadcast([]byte("a"))
+	output.broadcast([]byte("b"))
+	assert.False(t, output.listeners[slow])
+	assert.True(t, output.listeners[fast])
+	// Buffered chunks are still read before the closed channel
+	assert.Equal(t, "a", string(<-slow.C))
+	_, ok := <-slow.C
+	assert.False(t, ok)
+	assert.Len(t, fast.C, 2)
+}
+
+// Returns an ogg page with the given header type, granule position and
+// body split into segments of at most 255 bytes
+func oggPage(typ byte, granule uint64, body []byte) []byte {
+	var segments []byte
+	for n := len(body); ; n -= 255 {
+		if n < 255 {
+			segments = append(segments, byte(n))
+			break
+		}
+		segments = append(segments, 255)
+	}
+	header := make([]byte, 27)
+	copy(header, "OggS")
+	header[5] = typ
+	binary.LittleEndian.PutUint64(header[6:], granule)
+	header[26] = byte(len(segments))
+	page := append(header, segments...)
+	return append(page, body...)
+}
+
+func TestReadOggPage(t *testing.T) {
+	long := oggPage(0, 1, bytes.Repeat([]byte{1}, 300))
+	tt := []struct {
+		tname string
+		input []byte
+		page  []byte
+		err   bool
+	}{
+		{"page", oggPage(0, 1, []byte("abc")), oggPage(0, 1, []byte("abc")), false},
+		{"multiple segments", long, long, false},
+		{"invalid magic", append([]byte("Nope"), oggPage(0, 1, nil)[4:]...), nil, true},
+		{"truncated", long[:len(long)-1], nil, true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			page, err := readOggPage(bytes.NewReader(tc.input))
+			assert.Equal(t, tc.err, err != nil)
+			assert.Equal(t, tc.page, page)
+		})
+	}
+}
+
+func TestHTTPOutputOggHeader(t *testing.T) {
+	output := &HTTPOutput{listeners: make(map[*httpListener]bool)}
+	head := oggPage(0x02, 0, []byte("OpusHead"))
+	tags := oggPage(0, 0, []byte("OpusTags"))
+	audio := oggPage(0, 960, []byte("audio"))
+	restarted := oggPage(0x02, 0, []byte("OpusHead2"))
+	stream := bytes.Join([][]byte{head, tags, audio, restarted}, nil)
+	output.readOgg(bytes.NewReader(stream))
+	// Header pages are kept from the last beginning of stream
+	assert.Equal(t, restarted, output.header)
+	output.header = nil
+	output.readOgg(bytes.NewReader(stream[:len(head)+len(tags)+len(audio)]))
+	assert.Equal(t, append(head, tags...), output.header)
+}
@@ -3,11 +3,32 @@ package audio
 import (
 	"encoding/binary"
 	"io"
+	"math"
 	"sync"
+	"time"
 
 	"player/logger"
 )
 
+// An error ending an input early
+type InputError struct {
+	Write bool  // True if writing to the output failed, else reading the source
+	Err   error // The underlying error
+}
+
+func (e *InputError) Error() string {
+	if e.Write {
+		return "audio input write error: " + e.Err.Error()
+	}
+	return "audio input read error: " + e.Err.Error()
+}
+
+// Implemented by input sources which know when they have been fully
+// buffered, returning the bytes left to read once they have
+type Remainer interface {
+	Remaining() (int, bool)
+}
+
 // A input takes an audio input source and writes it to
 // an audio output source
 type Input struct {
@@ -15,10 +36,20 @@ type Input struct {
 	input io.Reader
 	// Audio output
 	output Writer
+	// Silence detection, nil to play the source as is
+	silence *Silence
+	// Why the input ended early, set before the end signal
+	err *InputError
+	// Ended on trailing silence, set before the end signal
+	trailing bool
+	// Samples written to the output
+	played int
 	// Orchestration channels
-	stopC   chan bool // Stop reading the source
-	resumeC chan bool // Resume reading the source
-	endC    chan bool // bool sent when finished
+	stopC    chan bool // Stop reading the source
+	resumeC  chan bool // Resume reading the source
+	endC     chan bool // bool sent when finished
+	deadAirC chan bool // bool sent when dead air is detected
+	bufferC  chan bool // Latest buffering state, true while waiting on the source
 	// Close orchestration
 	closeC  chan bool
 	closeWg *sync.WaitGroup
@@ -34,9 +65,12 @@ func (i *Input) play() {
 		logger.Debug("audio input complete")
 		i.endC <- true
 	}(i)
+	var stalledAt time.Time // When the input stopped producing samples
+	buffering := true       // Nothing has been read yet
 	for {
 		select {
 		case <-i.stopC:
+			stalledAt = time.Time{}
 			select {
 			case <-i.closeC:
 				return
@@ -46,26 +80,91 @@ func (i *Input) play() {
 		case <-i.closeC:
 			return
 		default:
-			frames := make([]int16, FRAMES_PER_BUFFER)
-			if err := binary.Read(i.input, binary.LittleEndian, frames); err != nil {
+			pcm := make([]int16, FRAMES_PER_BUFFER)
+			if err := binary.Read(i.input, binary.LittleEndian, pcm); err != nil {
 				switch err {
 				case io.ErrShortBuffer:
+					if stalledAt.IsZero() {
+						stalledAt = time.Now()
+					}
+					if !buffering {
+						buffering = true
+						i.setBuffering(true)
+					}
+					if i.silence != nil && i.silence.Stall(time.Since(stalledAt)) == DeadAir {
+						i.reportDeadAir()
+					}
 					continue // Wait for the buffer to fill
 				case io.EOF, io.ErrUnexpectedEOF:
 					return // We have completed reading the reader
 				default:
 					logger.WithError(err).Error("unexpected audio input read error")
+					i.err = &InputError{Err: err}
 					return
 				}
 			}
+			stalledAt = time.Time{}
+			if buffering {
+				buffering = false
+				i.setBuffering(false)
+			}
+			frames := Float32(pcm)
+			if i.silence != nil {
+				var state SilenceState
+				i.silence.Remaining(i.remaining())
+				frames, state = i.silence.Process(frames)
+				switch state {
+				case Ended:
+					logger.Debug("trailing silence, ending audio input")
+					i.trailing = true
+					return
+				case DeadAir:
+					i.reportDeadAir()
+				}
+			}
+			if len(frames) == 0 {
+				continue // Skip leading silence
+			}
 			if _, err := i.output.Write(frames); err != nil {
 				logger.WithError(err).Error("unexpected audio input write error")
+				i.err = &InputError{Write: true, Err: err}
 				return
 			}
+			i.played += len(frames)
 		}
 	}
 }
 
+// Returns how much of the source is left to read and whether it has been
+// fully buffered, sources which cannot tell are never fully buffered
+func (i *Input) remaining() (time.Duration, bool) {
+	r, ok := i.input.(Remainer)
+	if !ok {
+		return 0, false
+	}
+	n, buffered := r.Remaining()
+	return samplesDuration(n / 2), buffered
+}
+
+// Replaces the buffering state without blocking the read loop, only
+// the latest state matters
+func (i *Input) setBuffering(buffering bool) {
+	select {
+	case <-i.bufferC:
+	default:
+	}
+	i.bufferC <- buffering
+}
+
+// Sends the dead air signal without blocking the read loop
+func (i *Input) reportDeadAir() {
+	logger.Warn("dead air detected on audio input")
+	select {
+	case i.deadAirC <- true:
+	default:
+	}
+}
+
 // Starts the audip input play coroutine
 func (i *Input) Play() {
 	defer logger.Debug("play audio input")
@@ -92,6 +191,38 @@ func (i *Input) End() <-chan bool {
 	return (<-chan bool)(i.endC)
 }
 
+// Returns why the input ended early, nil if it played to the end or was
+// stopped, only valid once the input has ended
+func (i *Input) Err() error {
+	if i.err == nil {
+		return nil
+	}
+	return i.err
+}
+
+// Returns true if the input ended on trailing silence, only valid once
+// the input has ended
+func (i *Input) Trailing() bool {
+	return i.trailing
+}
+
+// Returns how much of the input has been played, only valid once the
+// input has ended or been closed
+func (i *Input) Played() time.Duration {
+	return samplesDuration(i.played)
+}
+
+// Returns the buffering state of the input, true when it is waiting on
+// its source and false once samples are flowing
+func (i *Input) Buffering() <-chan bool {
+	return (<-chan bool)(i.bufferC)
+}
+
+// Returns the dead air signal of the input
+func (i *Input) DeadAir() <-chan bool {
+	return (<-chan bool)(i.deadAirC)
+}
+
 // Stops playing a input midway through playback
 func (i *Input) Close() {
 	defer logger.Debug("input ")
@@ -99,18 +230,39 @@ func (i *Input) Close() {
 	i.closeWg.Wait()
 }
 
+// Converts signed 16 bit samples to float32 samples
+func Float32(pcm []int16) []float32 {
+	samples := make([]float32, len(pcm))
+	for i, s := range pcm {
+		samples[i] = float32(s) / (math.MaxInt16 + 1)
+	}
+	return samples
+}
+
 // Create a new input
 func NewInput(i io.Reader, o Writer) *Input {
 	return &Input{
 		// I/O
 		input:  i,
 		output: o,
+		// Silence detection
+		silence: NewSilence(NewConfig()),
 		// Orchestration Channels
-		stopC:   make(chan bool, 1),
-		resumeC: make(chan bool, 1),
-		endC:    make(chan bool, 1),
+		stopC:    make(chan bool, 1),
+		resumeC:  make(chan bool, 1),
+		endC:     make(chan bool, 1),
+		deadAirC: make(chan bool, 1),
+		bufferC:  make(chan bool, 1),
 		// Close orchestration
 		closeC:  make(chan bool, 1),
 		closeWg: &sync.WaitGroup{},
 	}
 }
+
+// Create a new input which plays its source as is, without trimming
+// silence or detecting dead air, e.g: for announcements
+func NewOverlayInput(i io.Reader, o Writer) *Input {
+	input := NewInput(i, o)
+	input.silence = nil
+	return input
+}
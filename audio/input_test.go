@@ -0,0 +1,106 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Reads a fully buffered source
+type bufferedReader struct {
+	*bytes.Reader
+}
+
+func (r bufferedReader) Remaining() (int, bool) {
+	return r.Len(), true
+}
+
+// Returns the given durations of audible audio then silence, alternating
+func pcm(durations ...time.Duration) []byte {
+	buf := &bytes.Buffer{}
+	for i, d := range durations {
+		v := int16(8000)
+		if i%2 == 1 {
+			v = 0
+		}
+		samples := make([]int16, int(d.Seconds()*SAMPLE_RATE)*CHANNELS)
+		for j := range samples {
+			samples[j] = v
+		}
+		binary.Write(buf, binary.LittleEndian, samples)
+	}
+	return buf.Bytes()
+}
+
+func TestInputSilence(t *testing.T) {
+	tt := []struct {
+		name     string
+		source   io.Reader
+		trailing bool
+		deadAir  bool
+	}{
+		{
+			"silence before the end is dead air",
+			bufferedReader{bytes.NewReader(pcm(time.Second, time.Second*12, time.Second*10))},
+			false,
+			true,
+		},
+		{
+			"silence at the end ends the track",
+			bufferedReader{bytes.NewReader(pcm(time.Second, time.Second*8))},
+			true,
+			false,
+		},
+		{
+			"source which cannot tell its end",
+			bytes.NewReader(pcm(time.Second, time.Second*8)),
+			false,
+			false,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			input := NewInput(tc.source, &testOutput{})
+			input.Play()
+			select {
+			case <-input.End():
+			case <-time.After(time.Second * 5):
+				t.Fatal("input did not end")
+			}
+			input.Close()
+			assert.Equal(t, tc.trailing, input.Trailing())
+			select {
+			case <-input.DeadAir():
+				assert.True(t, tc.deadAir, "unexpected dead air")
+			default:
+				assert.False(t, tc.deadAir, "dead air not reported")
+			}
+		})
+	}
+}
+
+func TestOverlayInputPlaysSilence(t *testing.T) {
+	// Leading silence and a pause longer than the trailing and dead air
+	// durations are played as is
+	source := bufferedReader{bytes.NewReader(pcm(0, time.Second, time.Second, time.Second*12, time.Second))}
+	input := NewOverlayInput(source, &testOutput{})
+	input.Play()
+	select {
+	case <-input.End():
+	case <-time.After(time.Second * 5):
+		t.Fatal("input did not end")
+	}
+	input.Close()
+	assert.False(t, input.Trailing())
+	// Less the final partial buffer
+	assert.InDelta(t, float64(time.Second*15), float64(input.Played()), float64(samplesDuration(FRAMES_PER_BUFFER)))
+	select {
+	case <-input.DeadAir():
+		t.Fatal("dead air reported for an overlay")
+	default:
+	}
+}
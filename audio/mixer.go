@@ -0,0 +1,287 @@
+package audio
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"player/logger"
+)
+
+var (
+	// Returned when writing to a closed mixer
+	ErrMixerClosed = errors.New("mixer closed")
+	// Returned by outputs when the device has failed and must be reopened
+	ErrOutputFailed = errors.New("audio output failed")
+)
+
+// How long the main input stays ducked after the overlay goes quiet,
+// bridges the gaps between overlay buffers
+const duckHold = time.Millisecond * 500
+
+// A tap receives a copy of every sample written to the output
+type Tap interface {
+	Tap([]float32)
+}
+
+// Taps attached to the mixer output
+var (
+	tapsLock = &sync.Mutex{}
+	taps     []Tap
+)
+
+// Attach a tap to the mixed output
+func AddTap(t Tap) {
+	tapsLock.Lock()
+	taps = append(taps, t)
+	tapsLock.Unlock()
+}
+
+// Writes samples to all attached taps
+func tap(samples []float32) {
+	tapsLock.Lock()
+	defer tapsLock.Unlock()
+	for _, t := range taps {
+		t.Tap(samples)
+	}
+}
+
+// A mixer input writer
+type mixerInput struct {
+	inputC chan []float32
+	closeC chan bool
+}
+
+// Push samples onto the mixer input queue
+func (i *mixerInput) Write(samples []float32) (int, error) {
+	select {
+	case <-i.closeC:
+		return 0, ErrMixerClosed
+	case i.inputC <- samples:
+		return len(samples), nil
+	}
+}
+
+// Mixes the main track input with an overlay input for announcements,
+// ducking the main input while the overlay is playing
+type Mixer struct {
+	// Exported Fields
+	Config DuckConfigurer
+	// Unexported Fields
+	output     Writer
+	outputLock sync.Mutex
+	main       *mixerInput
+	overlay    *mixerInput
+	pending    []float32  // Overlay samples not yet mixed
+	gain       float64    // Current main input gain
+	ducked     time.Time  // Last time overlay samples were mixed
+	resampler  *Resampler // Drift correction of the main input
+	// Output failure orchestration
+	failedC  chan error
+	swappedC chan bool
+	// Close orchestration
+	closeWg *sync.WaitGroup
+	closeC  chan bool
+}
+
+// Returns the main input resampler
+func (m *Mixer) Resampler() *Resampler {
+	return m.resampler
+}
+
+// Returns the main track input of the mixer
+func (m *Mixer) Main() Writer {
+	return m.main
+}
+
+// Returns the announcement overlay input of the mixer
+func (m *Mixer) Overlay() Writer {
+	return m.overlay
+}
+
+// Mixes pending overlay samples into the main samples, ramping the
+// main input gain towards the duck level while the overlay plays, the
+// mix is not clipped as it is clamped on conversion at the output
+func (m *Mixer) mix(main []float32) []float32 {
+	if len(m.pending) > 0 {
+		m.ducked = time.Now()
+	}
+	target := 1.0
+	if time.Since(m.ducked) < duckHold {
+		target = math.Pow(10, m.Config.DuckGain()/20)
+	}
+	step := 1.0
+	if ramp := m.Config.DuckRamp(); ramp > 0 {
+		step = 1 / (ramp.Seconds() * SAMPLE_RATE)
+	}
+	out := make([]float32, len(main))
+	for i := range out {
+		if i%CHANNELS == 0 {
+			if m.gain < target {
+				m.gain = math.Min(target, m.gain+step)
+			} else if m.gain > target {
+				m.gain = math.Max(target, m.gain-step)
+			}
+		}
+		out[i] = main[i] * float32(m.gain)
+		if i < len(m.pending) {
+			out[i] += m.pending[i]
+		}
+	}
+	if len(m.pending) > len(out) {
+		m.pending = m.pending[len(out):]
+	} else {
+		m.pending = nil
+	}
+	return out
+}
+
+// Reads the mixer inputs, writing the mixed samples to the output
+func (m *Mixer) run() {
+	logger.Debug("start audio mixer")
+	defer logger.Debug("exit audio mixer")
+	defer m.closeWg.Done()
+	var mainActive bool // Main samples were mixed in the last pass
+	for {
+		var main []float32
+		switch {
+		case len(m.pending) > 0 && !mainActive:
+			// Drain the overlay without waiting on the main input
+			select {
+			case main = <-m.main.inputC:
+			default:
+			}
+		case len(m.pending) > 0:
+			select {
+			case <-m.closeC:
+				return
+			case main = <-m.main.inputC:
+			case <-time.After(samplesDuration(len(m.pending))):
+			}
+		default:
+			select {
+			case <-m.closeC:
+				return
+			case main = <-m.main.inputC:
+			case m.pending = <-m.overlay.inputC:
+				if !mainActive {
+					break
+				}
+				// Give the playing main input a buffer's duration to catch
+				// up so the overlay doesn't open a gap in the main track
+				select {
+				case <-m.closeC:
+					return
+				case main = <-m.main.inputC:
+				case <-time.After(samplesDuration(len(m.pending))):
+				}
+			}
+		}
+		mainActive = main != nil
+		if main != nil {
+			main = m.resampler.Process(main)
+		}
+		if main == nil {
+			main = make([]float32, len(m.pending))
+			if len(main) > FRAMES_PER_BUFFER {
+				main = main[:FRAMES_PER_BUFFER]
+			}
+		}
+		samples := m.mix(main)
+		if !m.write(samples) {
+			return
+		}
+		tap(samples)
+	}
+}
+
+// Writes samples to the output, returning false if the mixer closed
+// while waiting for a failed output to be replaced
+func (m *Mixer) write(samples []float32) bool {
+	for {
+		// Not written under the lock so a hung output can be swapped
+		output := m.currentOutput()
+		_, err := output.Write(samples)
+		switch {
+		case err == nil:
+			return true
+		case output != m.currentOutput():
+			continue // Swapped and closed while writing, write to the new output
+		case err != ErrOutputFailed:
+			logger.WithError(err).Error("audio mixer output write error")
+			return true
+		}
+		// Block the inputs until the failed output is replaced, so the
+		// track resumes where it was
+		select {
+		case m.failedC <- err:
+		default:
+		}
+		for output == m.currentOutput() {
+			select {
+			case <-m.closeC:
+				return false
+			case <-m.swappedC:
+			}
+		}
+	}
+}
+
+// Returns the current output
+func (m *Mixer) currentOutput() Writer {
+	m.outputLock.Lock()
+	defer m.outputLock.Unlock()
+	return m.output
+}
+
+// Output failures, the mixer blocks until the output is replaced
+func (m *Mixer) Failed() <-chan error {
+	return (<-chan error)(m.failedC)
+}
+
+// Switches the mixer to a new output, returning the previous output,
+// a write to it may still be pending until it is closed
+func (m *Mixer) SetOutput(output Writer) Writer {
+	m.outputLock.Lock()
+	defer m.outputLock.Unlock()
+	old := m.output
+	m.output = output
+	select {
+	case m.swappedC <- true:
+	default:
+	}
+	return old
+}
+
+// Starts mixing the inputs to the output
+func (m *Mixer) Start() {
+	m.closeWg.Add(1)
+	go m.run()
+}
+
+// Stops the mixer, writes to the mixer inputs return ErrMixerClosed
+func (m *Mixer) Close() error {
+	logger.Debug("close audio mixer")
+	defer logger.Debug("closed audio mixer")
+	close(m.closeC)
+	m.closeWg.Wait()
+	return nil
+}
+
+// Constructs a new Mixer writing to the output
+func NewMixer(output Writer, c DuckConfigurer) *Mixer {
+	closeC := make(chan bool)
+	return &Mixer{
+		Config:    c,
+		output:    output,
+		main:      &mixerInput{make(chan []float32, INPUT_BUFFER_SIZE), closeC},
+		overlay:   &mixerInput{make(chan []float32, INPUT_BUFFER_SIZE), closeC},
+		gain:      1,
+		resampler: NewResampler(),
+		failedC:   make(chan error, 1),
+		swappedC:  make(chan bool, 1),
+		closeWg:   &sync.WaitGroup{},
+		closeC:    closeC,
+	}
+}
@@ -0,0 +1,130 @@
+package audio
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testDuckConfig struct {
+	gain float64
+	ramp time.Duration
+}
+
+func (c testDuckConfig) DuckGain() float64       { return c.gain }
+func (c testDuckConfig) DuckRamp() time.Duration { return c.ramp }
+
+// Output whose writes hang until it is closed
+type hungOutput struct {
+	writingC chan bool
+	closeC   chan bool
+	once     sync.Once
+}
+
+func (o *hungOutput) Write(data []float32) (int, error) {
+	select {
+	case o.writingC <- true:
+	default:
+	}
+	<-o.closeC
+	return 0, ErrOutputFailed
+}
+
+func (o *hungOutput) Close() error {
+	o.once.Do(func() { close(o.closeC) })
+	return nil
+}
+
+// Records writes, safe for concurrent use
+type syncOutput struct {
+	lock    sync.Mutex
+	written int
+}
+
+func (o *syncOutput) Write(data []float32) (int, error) {
+	o.lock.Lock()
+	o.written++
+	o.lock.Unlock()
+	return len(data), nil
+}
+
+func (o *syncOutput) Close() error { return nil }
+
+func TestMixerSetOutputWhileHung(t *testing.T) {
+	hung := &hungOutput{writingC: make(chan bool, 1), closeC: make(chan bool)}
+	m := NewMixer(hung, testDuckConfig{})
+	m.Start()
+	m.Main().Write(make([]float32, FRAMES_PER_BUFFER))
+	<-hung.writingC
+	next := &syncOutput{}
+	swappedC := make(chan Writer)
+	go func() { swappedC <- m.SetOutput(next) }()
+	select {
+	case old := <-swappedC:
+		assert.Equal(t, hung, old)
+	case <-time.After(time.Second):
+		t.Fatal("switching away from a hung output blocked")
+	}
+	// Closing the old output ends the pending write, which is retried
+	// on the new output rather than reported as a failure
+	hung.Close()
+	deadline := time.Now().Add(time.Second)
+	for {
+		next.lock.Lock()
+		written := next.written
+		next.lock.Unlock()
+		if written > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no samples written to the new output")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	select {
+	case err := <-m.Failed():
+		t.Fatalf("unexpected output failure: %s", err)
+	default:
+	}
+	assert.NoError(t, m.Close())
+}
+
+func TestMixerDuck(t *testing.T) {
+	// The gain changes by a quarter each frame
+	ramp := time.Second * 4 / SAMPLE_RATE
+	tt := []struct {
+		tname    string
+		ramp     time.Duration
+		gain     float64 // Main gain before mixing
+		overlay  bool
+		expected []float32 // Main gain of each frame
+	}{
+		{"no overlay", ramp, 1, false, []float32{1, 1, 1, 1, 1, 1}},
+		{"ramp down", ramp, 1, true, []float32{0.75, 0.5, 0.25, 0.1, 0.1, 0.1}},
+		{"no ramp", 0, 1, true, []float32{0.1, 0.1, 0.1, 0.1, 0.1, 0.1}},
+		{"ramp up", ramp, 0.1, false, []float32{0.35, 0.6, 0.85, 1, 1, 1}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			m := NewMixer(&testOutput{}, testDuckConfig{gain: -20, ramp: tc.ramp})
+			m.gain = tc.gain
+			frames := len(tc.expected)
+			main := make([]float32, frames*CHANNELS)
+			for i := range main {
+				main[i] = 1
+			}
+			if tc.overlay {
+				m.pending = make([]float32, len(main)) // Silent, but ducks all the same
+			}
+			out := m.mix(main)
+			gains := make([]float32, frames)
+			for f := range gains {
+				assert.Equal(t, out[f*CHANNELS], out[f*CHANNELS+1], "channels ducked together")
+				gains[f] = out[f*CHANNELS]
+			}
+			assert.Equal(t, tc.expected, round(gains))
+		})
+	}
+}
@@ -0,0 +1,31 @@
+// Null output, discards samples in real time
+
+package audio
+
+func init() {
+	Register("null", Null{})
+}
+
+// Null output backend, for running without a sound card
+type Null struct{}
+
+// Opens a null output
+func (Null) Open(c Configurer) (Output, error) {
+	return &NullOutput{}, nil
+}
+
+// Discards samples at the rate they would be played
+type NullOutput struct {
+	clock Clock
+}
+
+// Discards the samples once the previous samples would have been played
+func (output *NullOutput) Write(data []float32) (int, error) {
+	output.clock.Wait(len(data))
+	return len(data), nil
+}
+
+// Close the null output
+func (output *NullOutput) Close() error {
+	return nil
+}
@@ -0,0 +1,200 @@
+// Pipe output, writes samples to the stdin of an external command
+
+package audio
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"player/logger"
+)
+
+const (
+	pipeCloseTimeout = time.Second * 5 // How long to wait for the command to exit on close before killing it
+	pipeStallTimeout = time.Second * 2 // How long a write may stall before the command is killed
+)
+
+func init() {
+	Register("pipe", Pipe{})
+}
+
+// Pipe output backend
+type Pipe struct{}
+
+// Starts the configured command
+func (Pipe) Open(c Configurer) (Output, error) {
+	format, err := ParseFormat(c.PipeFormat())
+	if err != nil {
+		return nil, err
+	}
+	output := NewPipeOutput(c.PipeCommand(), c.PipeArgs(), format, NewQuantizer(c.Dither()), c.PipeRestart())
+	output.timeout = c.PipeTimeout()
+	if err := output.start(); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// Writes samples to a command, restarting the command if it exits
+type PipeOutput struct {
+	command   string
+	args      []string
+	format    Format
+	quantizer *Quantizer
+	restart   time.Duration
+	timeout   time.Duration // Time a write may stall before the command is killed, 0 disables
+	stdout    io.Writer     // Where the command output is written
+	// Running command
+	lock  sync.Mutex
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// Paces writes while the command is not running, nil if the caller
+	// paces writes
+	clock *Clock
+	// Close orchestration
+	closeWg *sync.WaitGroup
+	closeC  chan bool
+}
+
+// Starts the command
+func (output *PipeOutput) start() error {
+	cmd := exec.Command(output.command, output.args...)
+	cmd.Stdout = output.stdout
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	logger.WithFields(logger.F{
+		"command": output.command,
+		"args":    output.args,
+		"pid":     cmd.Process.Pid,
+	}).Debug("started audio pipe command")
+	output.lock.Lock()
+	defer output.lock.Unlock()
+	select {
+	case <-output.closeC:
+		// Closed while restarting, nothing will wait on the command
+		stdin.Close()
+		if err := cmd.Process.Kill(); err != nil {
+			logger.WithError(err).WithField("command", output.command).Warn("unable to kill audio pipe command")
+		}
+	default:
+		output.cmd = cmd
+		output.stdin = stdin
+	}
+	output.closeWg.Add(1)
+	go output.wait(cmd)
+	return nil
+}
+
+// Waits for the command to exit, restarting it unless the output
+// is closing
+func (output *PipeOutput) wait(cmd *exec.Cmd) {
+	defer output.closeWg.Done()
+	err := cmd.Wait()
+	output.lock.Lock()
+	if output.cmd == cmd {
+		output.cmd = nil
+		output.stdin = nil
+	}
+	output.lock.Unlock()
+	select {
+	case <-output.closeC:
+		return
+	default:
+	}
+	logger.WithError(err).WithField("command", output.command).Warn("audio pipe command exited")
+	for {
+		select {
+		case <-output.closeC:
+			return
+		case <-time.After(output.restart):
+		}
+		err := output.start()
+		if err == nil {
+			return
+		}
+		logger.WithError(err).WithField("command", output.command).Error("unable to restart audio pipe command")
+	}
+}
+
+// Encodes and writes samples to the command stdin, samples are discarded
+// in real time while the command is not running, a command which stalls
+// a write is killed and restarted
+func (output *PipeOutput) Write(data []float32) (int, error) {
+	output.lock.Lock()
+	cmd, stdin := output.cmd, output.stdin
+	output.lock.Unlock()
+	if stdin == nil {
+		if output.clock != nil {
+			output.clock.Wait(len(data))
+		}
+		return len(data), nil
+	}
+	if output.timeout > 0 {
+		stall := time.AfterFunc(output.timeout, func() {
+			logger.WithField("command", output.command).Error("audio pipe command stalled, killing it")
+			cmd.Process.Kill()
+		})
+		defer stall.Stop()
+	}
+	if _, err := stdin.Write(output.quantizer.Encode(output.format, data)); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Closes the command stdin and waits for it to exit, killing it if it
+// does not exit in time
+func (output *PipeOutput) Close() error {
+	output.lock.Lock()
+	close(output.closeC)
+	stdin := output.stdin
+	output.lock.Unlock()
+	if stdin != nil {
+		stdin.Close()
+	}
+	done := make(chan bool)
+	go func() {
+		output.closeWg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(pipeCloseTimeout):
+		output.lock.Lock()
+		cmd := output.cmd
+		output.lock.Unlock()
+		if cmd != nil {
+			logger.WithField("command", output.command).Warn("killing audio pipe command")
+			if err := cmd.Process.Kill(); err != nil {
+				return err
+			}
+		}
+		<-done
+	}
+	return nil
+}
+
+// Construct a new pipe output for a command
+func NewPipeOutput(command string, args []string, format Format, q *Quantizer, restart time.Duration) *PipeOutput {
+	return &PipeOutput{
+		command:   command,
+		args:      args,
+		format:    format,
+		quantizer: q,
+		restart:   restart,
+		timeout:   pipeStallTimeout,
+		stdout:    os.Stdout,
+		clock:     &Clock{},
+		closeWg:   &sync.WaitGroup{},
+		closeC:    make(chan bool),
+	}
+}
@@ -0,0 +1,90 @@
+package audio
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeOutputRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pipe")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	starts := filepath.Join(dir, "starts")
+	output := NewPipeOutput("sh", []string{"-c", "echo >> " + starts + "; exit 1"}, S16, NewQuantizer(false), time.Millisecond*100)
+	if !assert.NoError(t, output.start()) {
+		return
+	}
+	begin := time.Now()
+	for {
+		output.lock.Lock()
+		down := output.stdin == nil
+		output.lock.Unlock()
+		if down {
+			break
+		}
+		if time.Since(begin) > time.Second {
+			t.Fatal("command did not exit")
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+	// Writes are paced while the command is down rather than failing
+	n, err := output.Write(make([]float32, SAMPLE_RATE/10*CHANNELS))
+	assert.NoError(t, err)
+	assert.Equal(t, SAMPLE_RATE/10*CHANNELS, n)
+	time.Sleep(time.Millisecond*450 - time.Since(begin))
+	assert.NoError(t, output.Close())
+	b, err := ioutil.ReadFile(starts)
+	assert.NoError(t, err)
+	// Started once, then restarted at most once per restart delay
+	count := bytes.Count(b, []byte("\n"))
+	assert.True(t, count >= 3, "started %d times", count)
+	assert.True(t, count <= 5, "started %d times", count)
+	// Not restarted once closed
+	time.Sleep(time.Millisecond * 200)
+	b, _ = ioutil.ReadFile(starts)
+	assert.Equal(t, count, bytes.Count(b, []byte("\n")))
+}
+
+func TestPipeOutputWrite(t *testing.T) {
+	out := &bytes.Buffer{} // Read once the command has exited
+	output := NewPipeOutput("cat", nil, S16, NewQuantizer(false), time.Second)
+	output.stdout = out
+	if !assert.NoError(t, output.start()) {
+		return
+	}
+	samples := []float32{0, 0.5, -0.5, 1}
+	_, err := output.Write(samples)
+	assert.NoError(t, err)
+	assert.NoError(t, output.Close())
+	assert.Equal(t, NewQuantizer(false).Encode(S16, samples), out.Bytes())
+}
+
+func TestPipeOutputStall(t *testing.T) {
+	// Never reads its stdin
+	output := NewPipeOutput("sleep", []string{"10"}, S16, NewQuantizer(false), time.Second)
+	output.timeout = time.Millisecond * 100
+	if !assert.NoError(t, output.start()) {
+		return
+	}
+	done := make(chan error)
+	go func() {
+		// More than the pipe buffer holds
+		_, err := output.Write(make([]float32, 1024*1024))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(time.Second * 2):
+		t.Fatal("stalled write not failed")
+	}
+	assert.NoError(t, output.Close())
+}
@@ -6,73 +6,129 @@ package audio
 
 import (
 	"sync"
+	"time"
 
 	"player/logger"
 
 	"github.com/gordonklaus/portaudio"
 )
 
-// Audio output stream handler
-var output *Output
+// How long a write may wait for the stream before it has failed
+const paStallTimeout = time.Second * 2
 
-// Initialises the audio device for streaming
-func Open() error {
+func init() {
+	Register("portaudio", PortAudio{})
+}
+
+// Port Audio output backend
+type PortAudio struct{}
+
+// Initialises portaudio and opens the default output device
+func (PortAudio) Open(c Configurer) (Output, error) {
 	logger.Debug("initialize portaudio")
+	// Output sample format
+	format, err := ParseFormat(c.Format())
+	if err != nil {
+		return nil, err
+	}
 	// Start portaudio
 	if err := portaudio.Initialize(); err != nil {
-		return err
+		return nil, err
 	}
-	// Get host api
-	host, err := portaudio.DefaultHostApi()
+	// Select device
+	device, err := paDevice(c.Device())
 	if err != nil {
-		return err
+		portaudio.Terminate()
+		return nil, err
 	}
-	// Select device
-	device := host.DefaultOutputDevice
 	logger.WithFields(logger.F{
 		"name":       device.Name,
 		"sampleRate": device.DefaultSampleRate,
 	}).Debug("using portaudio device")
 	// Setup output writer
-	output = NewOutput(device, INPUT_BUFFER_SIZE)
-	return output.Start() // Start the outout writter
+	output := NewPortAudioOutput(device, INPUT_BUFFER_SIZE, format, NewQuantizer(c.Dither()))
+	if err := output.Start(); err != nil { // Start the outout writter
+		portaudio.Terminate()
+		return nil, err
+	}
+	return output, nil
 }
 
-// Closes the audio output and terminates portaudio
-func Close() error {
-	logger.Debug("close audio")
-	defer logger.Debug("closed audio")
-	if output != nil {
-		if err := output.Close(); err != nil {
-			return err
+// Lists portaudio output devices
+func (PortAudio) Devices() ([]Device, error) {
+	if err := portaudio.Initialize(); err != nil {
+		return nil, err
+	}
+	defer portaudio.Terminate()
+	devices, _, err := paDevices()
+	return devices, err
+}
+
+// Returns the output devices and their portaudio device info, must be
+// called with portaudio initialised
+func paDevices() ([]Device, []*portaudio.DeviceInfo, error) {
+	host, err := portaudio.DefaultHostApi()
+	if err != nil {
+		return nil, nil, err
+	}
+	all, err := portaudio.Devices()
+	if err != nil {
+		return nil, nil, err
+	}
+	var devices []Device
+	var infos []*portaudio.DeviceInfo
+	for i, info := range all {
+		if info.MaxOutputChannels < CHANNELS {
+			continue
 		}
+		devices = append(devices, Device{
+			Index:   i,
+			Name:    info.Name,
+			Default: info == host.DefaultOutputDevice,
+		})
+		infos = append(infos, info)
 	}
-	return portaudio.Terminate()
+	return devices, infos, nil
 }
 
-// Returs the current audio output writer
-func Get() (*Output, error) {
-	if output == nil {
-		return nil, ErrNoOutput
+// Returns the device info for an output device name or index, must be
+// called with portaudio initialised
+func paDevice(name string) (*portaudio.DeviceInfo, error) {
+	devices, infos, err := paDevices()
+	if err != nil {
+		return nil, err
 	}
-	return output, nil
+	device, err := FindDevice(devices, name)
+	if err != nil {
+		return nil, err
+	}
+	for i, d := range devices {
+		if d == device {
+			return infos[i], nil
+		}
+	}
+	return nil, ErrUnknownDevice
 }
 
-// Output handles writting to audio stream
-type Output struct {
+// Output handles writting to a portaudio stream
+type PortAudioOutput struct {
 	// Portaudio Stream
 	device *portaudio.DeviceInfo
 	stream *portaudio.Stream
-	// Input bytes from audio source
-	inputC   chan []int16
-	leftover []int16
+	// Sample format conversion
+	format    Format
+	quantizer *Quantizer
+	buf       []float32
+	// Input samples from audio source
+	inputC   chan []float32
+	leftover []float32
 	// Close orchestration
 	closeWg *sync.WaitGroup
 	closeC  chan bool
 }
 
 // Opens and starts a portaudio stream
-func (output *Output) Start() error {
+func (output *PortAudioOutput) Start() error {
 	if output.stream == nil {
 		logger.Debug("setup portaudio output stream")
 		// Stream parameters
@@ -80,8 +136,17 @@ func (output *Output) Start() error {
 		params.Output.Channels = CHANNELS
 		params.SampleRate = float64(SAMPLE_RATE)
 		params.FramesPerBuffer = FRAMES_PER_BUFFER
-		// Setup Stream
-		stream, err := portaudio.OpenStream(params, output.write)
+		// Setup Stream, portaudio converts to the device format
+		var write interface{}
+		switch output.format {
+		case F32:
+			write = output.writeF32
+		case S24:
+			write = output.writeS24
+		default:
+			write = output.writeS16
+		}
+		stream, err := portaudio.OpenStream(params, write)
 		if err != nil {
 			return err
 		}
@@ -98,8 +163,38 @@ func (output *Output) Start() error {
 	return nil
 }
 
-// Writes output to the audio device
-func (output *Output) write(out []int16) {
+// Writes 32 bit float output to the audio device
+func (output *PortAudioOutput) writeF32(out []float32) {
+	output.write(out)
+	for i, s := range out {
+		out[i] = clamp(s)
+	}
+}
+
+// Writes 24 bit output to the audio device
+func (output *PortAudioOutput) writeS24(out []portaudio.Int24) {
+	b := output.quantizer.Encode(S24, output.samples(len(out)))
+	for i := range out {
+		copy(out[i][:], b[i*3:])
+	}
+}
+
+// Writes 16 bit output to the audio device
+func (output *PortAudioOutput) writeS16(out []int16) {
+	output.quantizer.S16(out, output.samples(len(out)))
+}
+
+// Returns the next n samples from the input queue
+func (output *PortAudioOutput) samples(n int) []float32 {
+	if len(output.buf) != n {
+		output.buf = make([]float32, n)
+	}
+	output.write(output.buf)
+	return output.buf
+}
+
+// Fills out with samples from the input queue, padding with silence
+func (output *PortAudioOutput) write(out []float32) {
 	// Write previously saved samples.
 	i := copy(out, output.leftover)
 	output.leftover = output.leftover[i:]
@@ -113,22 +208,37 @@ func (output *Output) write(out []int16) {
 			}
 			i += n
 		default:
-			z := make([]int16, len(out)-i)
+			z := make([]float32, len(out)-i)
 			copy(out[i:], z)
 			return
 		}
 	}
 }
 
-// Push data onto our input channel queue
-func (output *Output) Write(data []int16) (int, error) {
-	output.inputC <- data
-	return len(data), nil
+// Push data onto our input channel queue, a stream which has stopped
+// consuming samples has failed, portaudio does not report it
+func (output *PortAudioOutput) Write(data []float32) (int, error) {
+	select {
+	case output.inputC <- data:
+		return len(data), nil
+	case <-time.After(paStallTimeout):
+		logger.Error("portaudio stream stalled")
+		return 0, ErrOutputFailed
+	}
 }
 
-// Stops the output stream writter and stops/closes the
-// portaudio stream
-func (output *Output) Close() error {
+// Returns the stream output latency plus samples queued for the stream
+func (output *PortAudioOutput) Latency() time.Duration {
+	queued := samplesDuration(len(output.inputC) * FRAMES_PER_BUFFER)
+	if output.stream == nil {
+		return queued
+	}
+	return queued + output.stream.Info().OutputLatency
+}
+
+// Stops the output stream writter, stops/closes the portaudio
+// stream and terminates portaudio
+func (output *PortAudioOutput) Close() error {
 	logger.Debug("close audio output")
 	defer logger.Debug("closed audio output")
 	close(output.closeC)
@@ -141,15 +251,17 @@ func (output *Output) Close() error {
 			return err
 		}
 	}
-	return nil
+	return portaudio.Terminate()
 }
 
-// Construct a new output handler
-func NewOutput(device *portaudio.DeviceInfo, bufferSize int) *Output {
-	return &Output{
-		device:  device,
-		inputC:  make(chan []int16, bufferSize),
-		closeWg: &sync.WaitGroup{},
-		closeC:  make(chan bool),
+// Construct a new portaudio output handler
+func NewPortAudioOutput(device *portaudio.DeviceInfo, bufferSize int, format Format, q *Quantizer) *PortAudioOutput {
+	return &PortAudioOutput{
+		device:    device,
+		format:    format,
+		quantizer: q,
+		inputC:    make(chan []float32, bufferSize),
+		closeWg:   &sync.WaitGroup{},
+		closeC:    make(chan bool),
 	}
 }
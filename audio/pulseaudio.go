@@ -3,111 +3,190 @@
 package audio
 
 import (
-	"bytes"
-	"encoding/binary"
-	"player/logger"
+	"os/exec"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	"player/logger"
 
 	pulse "github.com/mesilliac/pulse-simple"
 )
 
-func paStream() (*pulse.Stream, error) {
-	return pulse.Playback("SOON_ FM", "SOON_ FM", &pulse.SampleSpec{
-		pulse.SAMPLE_S16LE,
-		SAMPLE_RATE,
-		CHANNELS,
-	})
+// Pulse audio sample formats
+var paFormats = map[Format]pulse.SampleFormat{
+	S16: pulse.SAMPLE_S16LE,
+	S24: pulse.SAMPLE_S24LE,
+	F32: pulse.SAMPLE_FLOAT32LE,
 }
 
-// Audio output stream handler
-var output *Output
-
-// Initialises the audio device for streaming
-func Open() error {
-	output = NewOutput(INPUT_BUFFER_SIZE)
-	return output.Start() // Start the outout writter
+// Opens a playback stream on a sink name or index, an empty device
+// opens the default sink
+func paStream(name, device string, format Format) (*pulse.Stream, error) {
+	return pulse.NewStream("", name, pulse.STREAM_PLAYBACK, device, name, &pulse.SampleSpec{
+		Format:   paFormats[format],
+		Rate:     SAMPLE_RATE,
+		Channels: CHANNELS,
+	}, nil, nil)
 }
 
-// Closes the audio output and terminates portaudio
-func Close() error {
-	return output.Close()
+func init() {
+	Register("pulse", PulseAudio{})
 }
 
-// Returs the current audio output writer
-func Get() (*Output, error) {
-	if output == nil {
-		return nil, ErrNoOutput
+// Pulse audio output backend
+type PulseAudio struct{}
+
+// Opens a pulse audio playback stream
+func (PulseAudio) Open(c Configurer) (Output, error) {
+	format, err := ParseFormat(c.Format())
+	if err != nil {
+		return nil, err
+	}
+	output := NewPulseAudioOutput(c.PulseName(), c.Device(), INPUT_BUFFER_SIZE, format, NewQuantizer(c.Dither()))
+	if err := output.Start(); err != nil { // Start the outout writter
+		return nil, err
 	}
 	return output, nil
 }
 
-// Output handles writting to audio stream
-type Output struct {
+// Lists pulse audio sinks, the simple api has no introspection so
+// sinks are listed with pactl
+func (PulseAudio) Devices() ([]Device, error) {
+	out, err := exec.Command("pactl", "list", "short", "sinks").Output()
+	if err != nil {
+		return nil, err
+	}
+	info, err := exec.Command("pactl", "info").Output()
+	if err != nil {
+		return nil, err
+	}
+	var def string
+	for _, line := range strings.Split(string(info), "\n") {
+		if strings.HasPrefix(line, "Default Sink:") {
+			def = strings.TrimSpace(strings.TrimPrefix(line, "Default Sink:"))
+		}
+	}
+	var devices []Device
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		devices = append(devices, Device{
+			Index:   index,
+			Name:    fields[1],
+			Default: fields[1] == def,
+		})
+	}
+	return devices, nil
+}
+
+// Output handles writting to a pulse audio stream
+type PulseAudioOutput struct {
+	name   string // Client and stream name
+	device string // Sink name or index
 	stream *pulse.Stream
-	// Input bytes from audio source
-	inputC chan []int16
+	// Sample format conversion
+	format    Format
+	quantizer *Quantizer
+	// Input samples from audio source
+	inputC chan []float32
+	// Closed when the stream fails
+	failedC chan bool
 	// Close orchestration
 	closeWg *sync.WaitGroup
 	closeC  chan bool
 }
 
-// Opens and starts a portaudio stream
-func (output *Output) Start() error {
+// Opens and starts a pulse audio stream
+func (output *PulseAudioOutput) Start() error {
 	if output.stream == nil {
 		logger.Debug("setup pulseaudio output stream")
-		stream, err := paStream()
+		stream, err := paStream(output.name, output.device, output.format)
 		if err != nil {
-			return nil
+			return err
 		}
 		output.stream = stream
+		output.closeWg.Add(1)
 		go output.write()
 	}
 	return nil
 }
 
-// Writes output to pulseaudio
-func (output *Output) write() {
-	output.closeWg.Add(1)
+// Writes output to pulseaudio until closed or the stream fails
+func (output *PulseAudioOutput) write() {
 	defer output.closeWg.Done()
 	for {
 		select {
 		case <-output.closeC:
 			return
 		case samples := <-output.inputC:
-			buf := new(bytes.Buffer)
-			for _, s := range samples {
-				_ = binary.Write(buf, binary.LittleEndian, s)
-			}
-			if _, err := output.stream.Write(buf.Bytes()); err != nil {
-				logger.WithError(err).Warn("pulse audio stream write error")
-
+			buf := output.quantizer.Encode(output.format, samples)
+			if _, err := output.stream.Write(buf); err != nil {
+				logger.WithError(err).Error("pulse audio stream failed")
+				close(output.failedC)
+				return
 			}
 		}
 	}
 }
 
-// Push data onto our input channel queue
-func (output *Output) Write(data []int16) (int, error) {
-	output.inputC <- data
-	return len(data), nil
+// Push data onto our input channel queue, returns ErrOutputFailed
+// once the stream has failed
+func (output *PulseAudioOutput) Write(data []float32) (int, error) {
+	select {
+	case output.inputC <- data:
+		return len(data), nil
+	case <-output.failedC:
+		return 0, ErrOutputFailed
+	}
+}
+
+// Returns the stream latency plus samples queued for the stream
+func (output *PulseAudioOutput) Latency() time.Duration {
+	queued := samplesDuration(len(output.inputC) * FRAMES_PER_BUFFER)
+	if output.stream == nil {
+		return queued
+	}
+	usec, err := output.stream.Latency()
+	if err != nil {
+		logger.WithError(err).Debug("unable to get pulse audio latency")
+		return queued
+	}
+	return queued + time.Duration(usec)*time.Microsecond
 }
 
 // Close output
-func (output *Output) Close() error {
+func (output *PulseAudioOutput) Close() error {
 	close(output.closeC)
 	output.closeWg.Wait()
 	if output.stream != nil {
 		defer output.stream.Free()
-		defer output.stream.Drain()
+		select {
+		case <-output.failedC: // Nothing to drain to
+		default:
+			output.stream.Drain()
+		}
 	}
 	return nil
 }
 
-// Construct a new output handler
-func NewOutput(bufferSize int) *Output {
-	return &Output{
-		inputC:  make(chan []int16, bufferSize),
-		closeWg: &sync.WaitGroup{},
-		closeC:  make(chan bool),
+// Construct a new pulse audio output handler
+func NewPulseAudioOutput(name, device string, bufferSize int, format Format, q *Quantizer) *PulseAudioOutput {
+	return &PulseAudioOutput{
+		name:      name,
+		device:    device,
+		format:    format,
+		quantizer: q,
+		inputC:    make(chan []float32, bufferSize),
+		failedC:   make(chan bool),
+		closeWg:   &sync.WaitGroup{},
+		closeC:    make(chan bool),
 	}
 }
@@ -0,0 +1,238 @@
+// RTP receiver, plays a stream sent by the rtp backend
+
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"player/logger"
+)
+
+// How long without packets before a stream is considered stopped and
+// the jitter buffer refills before playing again
+const rtpStreamTimeout = time.Second
+
+// A running receiver
+type Receiver interface {
+	Close() error
+}
+
+// Starts receiving the configured rtp stream, writing samples to output
+func Receive(c Configurer, output Writer) (Receiver, error) {
+	switch c.RTPCodec() {
+	case "l16":
+		r, err := NewRTPReceiver(c, output)
+		if err != nil {
+			return nil, err
+		}
+		r.Start()
+		return r, nil
+	case "opus":
+		return receiveOpus(c, output)
+	}
+	return nil, ErrUnknownRTPCodec
+}
+
+// Listens on an address, joining the group if it is multicast
+func listenRTP(address string) (*net.UDPConn, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	if addr.IP.IsMulticast() {
+		return net.ListenMulticastUDP("udp", nil, addr)
+	}
+	return net.ListenUDP("udp", addr)
+}
+
+// Receives L16 RTP packets, reordering them in a jitter buffer and
+// concealing lost packets with silence
+type RTPReceiver struct {
+	conn    *net.UDPConn
+	output  Writer
+	lock    sync.Mutex
+	jitter  *JitterBuffer
+	ssrc    uint32    // Source of the current stream
+	last    time.Time // When the last packet was received
+	packetC chan bool // Signals a packet has been buffered
+	// Close orchestration
+	closeWg *sync.WaitGroup
+	closeC  chan bool
+}
+
+// Reads packets into the jitter buffer
+func (r *RTPReceiver) read() {
+	defer r.closeWg.Done()
+	buf := make([]byte, 65536)
+	for {
+		n, err := r.conn.Read(buf)
+		if err != nil {
+			select {
+			case <-r.closeC:
+				return
+			default:
+			}
+			logger.WithError(err).Warn("rtp receive error")
+			continue
+		}
+		p := &RTPPacket{}
+		if err := p.Unmarshal(append([]byte(nil), buf[:n]...)); err != nil {
+			logger.WithError(err).Debug("dropping rtp packet")
+			continue
+		}
+		if p.PayloadType != rtpL16Stereo {
+			continue
+		}
+		r.lock.Lock()
+		if p.SSRC != r.ssrc || time.Since(r.last) > rtpStreamTimeout {
+			// New or restarted stream
+			logger.WithField("ssrc", p.SSRC).Info("receiving rtp stream")
+			r.jitter.Reset()
+			r.ssrc = p.SSRC
+		}
+		r.last = time.Now()
+		r.jitter.Push(p)
+		r.lock.Unlock()
+		select {
+		case r.packetC <- true:
+		default:
+		}
+	}
+}
+
+// Plays packets out of the jitter buffer in order
+func (r *RTPReceiver) play() {
+	defer r.closeWg.Done()
+	for {
+		r.lock.Lock()
+		p, ok := r.jitter.Pop()
+		r.lock.Unlock()
+		if !ok {
+			select {
+			case <-r.closeC:
+				return
+			case <-r.packetC:
+			}
+			continue
+		}
+		var samples []float32
+		if p == nil {
+			samples = make([]float32, rtpFrames*CHANNELS) // Conceal the lost packet
+		} else {
+			samples = make([]float32, len(p.Payload)/2)
+			for i := range samples {
+				samples[i] = float32(int16(binary.BigEndian.Uint16(p.Payload[i*2:]))) / 32768
+			}
+		}
+		if _, err := r.output.Write(samples); err != nil {
+			logger.WithError(err).Error("rtp receiver write error")
+		}
+	}
+}
+
+// Starts receiving and playing
+func (r *RTPReceiver) Start() {
+	r.closeWg.Add(2)
+	go r.read()
+	go r.play()
+}
+
+// Stops receiving
+func (r *RTPReceiver) Close() error {
+	close(r.closeC)
+	err := r.conn.Close()
+	r.closeWg.Wait()
+	return err
+}
+
+// Constructs a new L16 RTP receiver listening on the configured address
+func NewRTPReceiver(c Configurer, output Writer) (*RTPReceiver, error) {
+	conn, err := listenRTP(c.RTPAddress())
+	if err != nil {
+		return nil, err
+	}
+	depth := int(c.RTPJitter() / samplesDuration(rtpFrames*CHANNELS))
+	return &RTPReceiver{
+		conn:    conn,
+		output:  output,
+		jitter:  NewJitterBuffer(depth),
+		packetC: make(chan bool, 1),
+		closeWg: &sync.WaitGroup{},
+		closeC:  make(chan bool),
+	}, nil
+}
+
+// Receives opus RTP with the encoder, described to it with an SDP file
+type opusReceiver struct {
+	cmd *exec.Cmd
+	sdp string // SDP file path
+}
+
+// Stops the decoder
+func (r *opusReceiver) Close() error {
+	defer os.Remove(r.sdp)
+	if err := r.cmd.Process.Kill(); err != nil {
+		return err
+	}
+	r.cmd.Wait()
+	return nil
+}
+
+// Starts decoding an opus RTP stream, writing samples to output
+func receiveOpus(c Configurer, output Writer) (Receiver, error) {
+	decoder, err := exec.LookPath(c.RTPEncoder())
+	if err != nil {
+		return nil, ErrNoEncoder
+	}
+	host, port, err := net.SplitHostPort(c.RTPAddress())
+	if err != nil {
+		return nil, err
+	}
+	sdp, err := ioutil.TempFile("", "sfmplayer-rtp")
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(sdp, "v=0\r\no=- 0 0 IN IP4 %s\r\ns=SOON_ FM\r\nc=IN IP4 %s\r\nt=0 0\r\nm=audio %s RTP/AVP %d\r\na=rtpmap:%d opus/48000/2\r\n",
+		host, host, port, rtpOpus, rtpOpus)
+	sdp.Close()
+	cmd := exec.Command(decoder,
+		"-hide_banner", "-loglevel", "error",
+		"-protocol_whitelist", "file,udp,rtp",
+		"-i", sdp.Name(),
+		"-f", "s16le", "-ar", strconv.Itoa(SAMPLE_RATE), "-ac", strconv.Itoa(CHANNELS), "-")
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		os.Remove(sdp.Name())
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		os.Remove(sdp.Name())
+		return nil, err
+	}
+	go func() {
+		buf := make([]byte, FRAMES_PER_BUFFER*CHANNELS*2)
+		for {
+			if _, err := io.ReadFull(stdout, buf); err != nil {
+				return
+			}
+			samples := make([]float32, len(buf)/2)
+			for i := range samples {
+				samples[i] = float32(int16(binary.LittleEndian.Uint16(buf[i*2:]))) / 32768
+			}
+			if _, err := output.Write(samples); err != nil {
+				logger.WithError(err).Error("rtp receiver write error")
+			}
+		}
+	}()
+	return &opusReceiver{cmd: cmd, sdp: sdp.Name()}, nil
+}
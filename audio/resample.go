@@ -0,0 +1,72 @@
+package audio
+
+import "sync"
+
+// Resamples the main input by a ratio close to 1 with linear
+// interpolation, used to correct small clock drift between players,
+// and counts the source frames consumed
+type Resampler struct {
+	lock   sync.Mutex
+	ratio  float64   // Source frames consumed per output frame
+	pos    float64   // Position of the next output frame, from prev
+	prev   []float32 // Last source frame of the previous buffer
+	frames int64     // Source frames consumed
+}
+
+// Sets the resampling ratio, above 1 plays faster, below 1 slower
+func (r *Resampler) SetRatio(ratio float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.ratio = ratio
+}
+
+// Returns the number of source frames consumed since the last reset
+func (r *Resampler) Frames() int64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.frames
+}
+
+// Resets the consumed frame count
+func (r *Resampler) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.frames = 0
+}
+
+// Resamples a buffer of interleaved samples
+func (r *Resampler) Process(in []float32) []float32 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	n := len(in) / CHANNELS
+	r.frames += int64(n)
+	// Source frame i, where frame 0 is the last frame of the previous buffer
+	frame := func(i, c int) float32 {
+		if i == 0 {
+			return r.prev[c]
+		}
+		return in[(i-1)*CHANNELS+c]
+	}
+	out := make([]float32, 0, int(float64(n)/r.ratio+2)*CHANNELS)
+	for r.pos < float64(n) {
+		i := int(r.pos)
+		frac := float32(r.pos - float64(i))
+		for c := 0; c < CHANNELS; c++ {
+			out = append(out, frame(i, c)*(1-frac)+frame(i+1, c)*frac)
+		}
+		r.pos += r.ratio
+	}
+	r.pos -= float64(n)
+	if n > 0 {
+		copy(r.prev, in[(n-1)*CHANNELS:])
+	}
+	return out
+}
+
+// Constructs a new resampler at a ratio of 1
+func NewResampler() *Resampler {
+	return &Resampler{
+		ratio: 1,
+		prev:  make([]float32, CHANNELS),
+	}
+}
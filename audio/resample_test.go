@@ -0,0 +1,41 @@
+package audio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResamplerUnity(t *testing.T) {
+	r := NewResampler()
+	out := r.Process([]float32{1, -1, 2, -2})
+	out = append(out, r.Process([]float32{3, -3})...)
+	// Delayed by a single frame
+	assert.Equal(t, []float32{0, 0, 1, -1, 2, -2}, out)
+	assert.Equal(t, int64(3), r.Frames())
+	r.Reset()
+	assert.Equal(t, int64(0), r.Frames())
+}
+
+func TestResamplerRatio(t *testing.T) {
+	tt := []struct {
+		tname string
+		ratio float64
+	}{
+		{"faster", 1.001},
+		{"slower", 0.999},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			r := NewResampler()
+			r.SetRatio(tc.ratio)
+			in := make([]float32, FRAMES_PER_BUFFER*CHANNELS)
+			var frames int
+			for i := 0; i < 100; i++ {
+				frames += len(r.Process(in)) / CHANNELS
+			}
+			expected := float64(FRAMES_PER_BUFFER*100) / tc.ratio
+			assert.InDelta(t, expected, float64(frames), 1)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// RTP output, sends audio over UDP multicast or unicast for
+// multi-room speakers
+
+package audio
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+var ErrUnknownRTPCodec = errors.New("unknown rtp codec, must be l16 or opus")
+
+func init() {
+	Register("rtp", RTP{})
+}
+
+// RTP output backend
+type RTP struct{}
+
+// Opens a UDP socket for L16, or starts an encoder sending opus
+func (RTP) Open(c Configurer) (Output, error) {
+	switch c.RTPCodec() {
+	case "l16":
+		addr, err := net.ResolveUDPAddr("udp", c.RTPAddress())
+		if err != nil {
+			return nil, err
+		}
+		conn, err := net.DialUDP("udp", nil, addr)
+		if err != nil {
+			return nil, err
+		}
+		return NewRTPOutput(conn, NewQuantizer(c.Dither())), nil
+	case "opus":
+		encoder, err := exec.LookPath(c.RTPEncoder())
+		if err != nil {
+			return nil, ErrNoEncoder
+		}
+		args := []string{
+			"-hide_banner", "-loglevel", "error",
+			"-f", "s16le", "-ar", strconv.Itoa(SAMPLE_RATE), "-ac", strconv.Itoa(CHANNELS), "-i", "-",
+			"-c:a", "libopus", "-b:a", c.RTPBitrate(),
+			"-payload_type", strconv.Itoa(rtpOpus),
+			"-f", "rtp", "rtp://" + c.RTPAddress(),
+		}
+		output := NewPipeOutput(encoder, args, S16, NewQuantizer(c.Dither()), time.Second)
+		if err := output.start(); err != nil {
+			return nil, err
+		}
+		return &pacedOutput{Output: output}, nil
+	}
+	return nil, ErrUnknownRTPCodec
+}
+
+// Paces writes to an output which would otherwise consume samples as
+// fast as they are written
+type pacedOutput struct {
+	Output
+	clock Clock
+}
+
+// Writes samples once the previous samples would have been played
+func (output *pacedOutput) Write(data []float32) (int, error) {
+	output.clock.Wait(len(data))
+	return output.Output.Write(data)
+}
+
+// Sends samples as L16 RTP packets, paced in real time
+type RTPOutput struct {
+	conn      *net.UDPConn
+	quantizer *Quantizer
+	clock     Clock
+	packet    RTPPacket
+	pending   []float32 // Samples not yet sent, less than a packet
+}
+
+// Sends a packet of samples as big endian 16 bit integers
+func (output *RTPOutput) send(samples []float32) error {
+	payload := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		v := uint16(output.quantizer.quantize(s, 16))
+		payload[i*2], payload[i*2+1] = byte(v>>8), byte(v)
+	}
+	output.packet.Payload = payload
+	_, err := output.conn.Write(output.packet.Marshal())
+	output.packet.Seq++
+	output.packet.Timestamp += uint32(len(samples) / CHANNELS)
+	output.packet.Marker = false
+	return err
+}
+
+// Sends whole packets of samples, keeping any remainder for the next write
+func (output *RTPOutput) Write(data []float32) (int, error) {
+	output.clock.Wait(len(data))
+	output.pending = append(output.pending, data...)
+	n := rtpFrames * CHANNELS
+	for len(output.pending) >= n {
+		err := output.send(output.pending[:n])
+		output.pending = output.pending[n:]
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// Close the socket
+func (output *RTPOutput) Close() error {
+	return output.conn.Close()
+}
+
+// Construct a new L16 RTP output sending on a connected UDP socket
+func NewRTPOutput(conn *net.UDPConn, q *Quantizer) *RTPOutput {
+	// Random initial sequence number, timestamp and source per RFC 3550
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return &RTPOutput{
+		conn:      conn,
+		quantizer: q,
+		packet: RTPPacket{
+			PayloadType: rtpL16Stereo,
+			Marker:      true,
+			Seq:         uint16(r.Uint32()),
+			Timestamp:   r.Uint32(),
+			SSRC:        r.Uint32(),
+		},
+	}
+}
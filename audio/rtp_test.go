@@ -0,0 +1,135 @@
+package audio
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRTPPacketMarshal(t *testing.T) {
+	p := &RTPPacket{
+		PayloadType: rtpL16Stereo,
+		Marker:      true,
+		Seq:         65535,
+		Timestamp:   1234,
+		SSRC:        0xdeadbeef,
+		Payload:     []byte{1, 2, 3, 4},
+	}
+	b := p.Marshal()
+	assert.Equal(t, []byte{0x80, 0x8a, 0xff, 0xff, 0, 0, 0x04, 0xd2, 0xde, 0xad, 0xbe, 0xef, 1, 2, 3, 4}, b)
+	u := &RTPPacket{}
+	assert.NoError(t, u.Unmarshal(b))
+	assert.Equal(t, p, u)
+	assert.Equal(t, ErrInvalidRTP, u.Unmarshal([]byte{0x80, 0}))
+}
+
+func TestJitterBuffer(t *testing.T) {
+	tt := []struct {
+		tname    string
+		depth    int
+		push     []uint16
+		expected []int // Sequence numbers popped, -1 for a lost packet
+	}{
+		{"in order", 2, []uint16{1, 2, 3}, []int{1, 2, 3}},
+		{"reordered", 3, []uint16{2, 1, 3}, []int{1, 2, 3}},
+		{"wraps", 2, []uint16{65535, 0, 1}, []int{65535, 0, 1}},
+		{"lost", 2, []uint16{1, 3, 4}, []int{1, -1, 3, 4}},
+		{"not enough", 3, []uint16{1, 2}, nil},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			j := NewJitterBuffer(tc.depth)
+			for _, seq := range tc.push {
+				j.Push(&RTPPacket{Seq: seq})
+			}
+			var popped []int
+			for {
+				p, ok := j.Pop()
+				if !ok {
+					break
+				}
+				if p == nil {
+					popped = append(popped, -1)
+				} else {
+					popped = append(popped, int(p.Seq))
+				}
+			}
+			assert.Equal(t, tc.expected, popped)
+		})
+	}
+}
+
+func TestJitterBufferDropsLate(t *testing.T) {
+	j := NewJitterBuffer(1)
+	j.Push(&RTPPacket{Seq: 5})
+	p, ok := j.Pop()
+	assert.True(t, ok)
+	assert.Equal(t, uint16(5), p.Seq)
+	j.Push(&RTPPacket{Seq: 4})
+	_, ok = j.Pop()
+	assert.False(t, ok)
+}
+
+type captureWriter struct {
+	lock    sync.Mutex
+	samples []float32
+}
+
+func (w *captureWriter) Write(s []float32) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.samples = append(w.samples, s...)
+	return len(s), nil
+}
+
+func (w *captureWriter) len() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return len(w.samples)
+}
+
+type rtpTestConfig struct {
+	Config
+}
+
+func (rtpTestConfig) RTPAddress() string {
+	return "127.0.0.1:15004"
+}
+
+func (rtpTestConfig) RTPJitter() time.Duration {
+	return time.Millisecond * 20
+}
+
+func TestRTPLoopback(t *testing.T) {
+	w := &captureWriter{}
+	r, err := NewRTPReceiver(rtpTestConfig{}, w)
+	assert.NoError(t, err)
+	r.Start()
+	defer r.Close()
+	addr, err := net.ResolveUDPAddr("udp", rtpTestConfig{}.RTPAddress())
+	assert.NoError(t, err)
+	conn, err := net.DialUDP("udp", nil, addr)
+	assert.NoError(t, err)
+	output := NewRTPOutput(conn, NewQuantizer(false))
+	defer output.Close()
+	in := make([]float32, rtpFrames*CHANNELS*8)
+	for i := range in {
+		in[i] = float32(i%100) / 100
+	}
+	n, err := output.Write(in)
+	assert.NoError(t, err)
+	assert.Equal(t, len(in), n)
+	deadline := time.Now().Add(time.Second)
+	for w.len() < len(in) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	assert.Len(t, w.samples, len(in))
+	for i := range in {
+		assert.InDelta(t, in[i], w.samples[i], 1.0/32768)
+	}
+}
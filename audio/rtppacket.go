@@ -0,0 +1,141 @@
+// RTP packets and jitter buffer
+
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var ErrInvalidRTP = errors.New("invalid rtp packet")
+
+// RTP payload types
+const (
+	rtpL16Stereo = 10 // RFC 3551 L16, 2 channels at 44.1kHz
+	rtpOpus      = 97 // Dynamic, as used by ffmpeg for opus
+)
+
+// Size of an RTP header without CSRCs or extensions
+const rtpHeaderSize = 12
+
+// Audio frames sent in each L16 packet, keeps packets under a typical MTU
+const rtpFrames = 256
+
+// An RTP packet
+type RTPPacket struct {
+	PayloadType uint8
+	Marker      bool
+	Seq         uint16
+	Timestamp   uint32
+	SSRC        uint32
+	Payload     []byte
+}
+
+// Encodes the packet
+func (p *RTPPacket) Marshal() []byte {
+	b := make([]byte, rtpHeaderSize+len(p.Payload))
+	b[0] = 2 << 6 // Version 2, no padding, extension or CSRCs
+	b[1] = p.PayloadType & 0x7f
+	if p.Marker {
+		b[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(b[2:], p.Seq)
+	binary.BigEndian.PutUint32(b[4:], p.Timestamp)
+	binary.BigEndian.PutUint32(b[8:], p.SSRC)
+	copy(b[rtpHeaderSize:], p.Payload)
+	return b
+}
+
+// Decodes a packet, skipping any CSRCs, extension and padding
+func (p *RTPPacket) Unmarshal(b []byte) error {
+	if len(b) < rtpHeaderSize || b[0]>>6 != 2 {
+		return ErrInvalidRTP
+	}
+	p.PayloadType = b[1] & 0x7f
+	p.Marker = b[1]&0x80 != 0
+	p.Seq = binary.BigEndian.Uint16(b[2:])
+	p.Timestamp = binary.BigEndian.Uint32(b[4:])
+	p.SSRC = binary.BigEndian.Uint32(b[8:])
+	offset := rtpHeaderSize + int(b[0]&0x0f)*4
+	if b[0]&0x10 != 0 { // Extension
+		if len(b) < offset+4 {
+			return ErrInvalidRTP
+		}
+		offset += 4 + int(binary.BigEndian.Uint16(b[offset+2:]))*4
+	}
+	end := len(b)
+	if b[0]&0x20 != 0 { // Padding
+		end -= int(b[len(b)-1])
+	}
+	if offset > end {
+		return ErrInvalidRTP
+	}
+	p.Payload = b[offset:end]
+	return nil
+}
+
+// Returns true if sequence number a comes before b, allowing for wrap
+func seqBefore(a, b uint16) bool {
+	return int16(a-b) < 0
+}
+
+// Reorders packets by sequence number, holding depth packets before
+// playing out so late packets can be put back in order
+type JitterBuffer struct {
+	depth   int
+	packets map[uint16]*RTPPacket
+	next    uint16 // Next sequence number to play out
+	started bool
+}
+
+// Adds a packet, packets which are late or duplicated are dropped
+func (j *JitterBuffer) Push(p *RTPPacket) {
+	if j.started && seqBefore(p.Seq, j.next) {
+		return
+	}
+	j.packets[p.Seq] = p
+}
+
+// Returns the next packet in order. A nil packet with ok true means the
+// next packet was lost and the caller should conceal it. ok is false
+// when not enough packets are buffered to play out.
+func (j *JitterBuffer) Pop() (*RTPPacket, bool) {
+	if !j.started {
+		if len(j.packets) < j.depth {
+			return nil, false
+		}
+		// Start from the oldest packet
+		first := true
+		for seq := range j.packets {
+			if first || seqBefore(seq, j.next) {
+				j.next, first = seq, false
+			}
+		}
+		j.started = true
+	}
+	p, found := j.packets[j.next]
+	if !found && len(j.packets) < j.depth {
+		return nil, false // Wait for it to arrive
+	}
+	delete(j.packets, j.next)
+	j.next++
+	return p, true
+}
+
+// Forgets all packets and waits for depth packets before playing
+// out again, used when a stream restarts
+func (j *JitterBuffer) Reset() {
+	j.packets = make(map[uint16]*RTPPacket)
+	j.started = false
+}
+
+// Constructs a new jitter buffer holding depth packets
+func NewJitterBuffer(depth int) *JitterBuffer {
+	if depth < 1 {
+		depth = 1
+	}
+	return &JitterBuffer{
+		depth:   depth,
+		packets: make(map[uint16]*RTPPacket),
+	}
+}
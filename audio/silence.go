@@ -0,0 +1,116 @@
+package audio
+
+import (
+	"math"
+	"time"
+)
+
+// Silence detector states
+type SilenceState int
+
+const (
+	Audible SilenceState = iota // Samples are above the silence threshold
+	Silent                      // Samples are below the silence threshold
+	DeadAir                     // Silence has persisted past the dead air duration
+	Ended                       // Silence has persisted past the trailing duration
+)
+
+// Detects silence in an audio input, trimming leading silence and
+// tracking how long silence has persisted after the track has started
+type Silence struct {
+	threshold float32       // Peak sample amplitude considered silent
+	trailing  time.Duration // Silence which ends the track, 0 disables
+	deadAir   time.Duration // Silence which reports dead air, 0 disables
+	started   bool          // An audible sample has been seen
+	silent    time.Duration // Decoded silence since the last audible sample
+	stalled   time.Duration // Time the input has been stalled
+	reported  bool          // Dead air has been reported for this silence
+	ending    bool          // The rest of the input is buffered and within the trailing duration
+}
+
+// Returns the duration of the given number of interleaved samples
+func samplesDuration(n int) time.Duration {
+	return time.Duration(n/CHANNELS) * time.Second / SAMPLE_RATE
+}
+
+// Returns true if the sample is below the silence threshold
+func (s *Silence) isSilent(sample float32) bool {
+	if sample < 0 {
+		return sample >= -s.threshold
+	}
+	return sample <= s.threshold
+}
+
+// Returns the current state based on how long silence has persisted
+func (s *Silence) state() SilenceState {
+	if s.trailing > 0 && s.ending && s.silent >= s.trailing {
+		return Ended
+	}
+	if s.deadAir > 0 && !s.reported && s.silent+s.stalled >= s.deadAir {
+		s.reported = true
+		return DeadAir
+	}
+	return Silent
+}
+
+// Processes a buffer of interleaved samples, returning the samples to
+// write to the output with any leading silence removed along with the
+// silence state after the buffer
+func (s *Silence) Process(samples []float32) ([]float32, SilenceState) {
+	s.stalled = 0
+	for i := len(samples) - 1; i >= 0; i-- {
+		if s.isSilent(samples[i]) {
+			continue
+		}
+		// Audible samples in this buffer, trim leading silence up
+		// to the start of the frame holding the first audible sample
+		if !s.started {
+			s.started = true
+			for j, sample := range samples {
+				if !s.isSilent(sample) {
+					samples = samples[j-j%CHANNELS:]
+					break
+				}
+			}
+		}
+		s.silent = samplesDuration(len(samples) - 1 - i)
+		s.reported = false
+		return samples, Audible
+	}
+	if !s.started {
+		return nil, Silent
+	}
+	s.silent += samplesDuration(len(samples))
+	return samples, s.state()
+}
+
+// Records how much of the input is left to read and whether it has been
+// fully buffered, silence only ends the track once the rest of it is
+// buffered and no longer than the trailing duration, before then it is
+// reported as dead air
+func (s *Silence) Remaining(d time.Duration, buffered bool) {
+	s.ending = buffered && d <= s.trailing
+}
+
+// Records that the input has produced no samples for the given duration,
+// stalls count towards dead air but never end the track
+func (s *Silence) Stall(d time.Duration) SilenceState {
+	s.stalled = d
+	if !s.started {
+		s.silent = 0
+	}
+	state := s.state()
+	if state == Ended {
+		return Silent
+	}
+	return state
+}
+
+// Constructs a new silence detector
+func NewSilence(c Configurer) *Silence {
+	return &Silence{
+		threshold: float32(math.Pow(10, c.SilenceThreshold()/20)),
+		trailing:  c.SilenceTrailing(),
+		deadAir:   c.SilenceDeadAir(),
+	}
+}
@@ -0,0 +1,85 @@
+package audio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns a buffer of the given number of frames at a constant level
+func level(frames int, v float32) []float32 {
+	samples := make([]float32, frames*CHANNELS)
+	for i := range samples {
+		samples[i] = v
+	}
+	return samples
+}
+
+func TestSilenceProcess(t *testing.T) {
+	tt := []struct {
+		name     string
+		buffers  [][]float32
+		ending   bool
+		expected SilenceState
+		written  int
+	}{
+		{
+			"trims leading silence",
+			[][]float32{level(512, 0), append(level(10, 0.0001), level(20, 0.2)...)},
+			true,
+			Audible,
+			20 * CHANNELS,
+		},
+		{
+			"silence before start",
+			[][]float32{level(SAMPLE_RATE*10, 0)},
+			true,
+			Silent,
+			0,
+		},
+		{
+			"trailing silence ends track",
+			[][]float32{level(512, 0.2), level(SAMPLE_RATE*2, 0)},
+			true,
+			Ended,
+			SAMPLE_RATE * 2 * CHANNELS,
+		},
+		{
+			"silence before the end is dead air",
+			[][]float32{level(512, 0.2), level(SAMPLE_RATE*6, 0)},
+			false,
+			DeadAir,
+			SAMPLE_RATE * 6 * CHANNELS,
+		},
+		{
+			"mid track silence",
+			[][]float32{level(512, 0.2), level(SAMPLE_RATE/2, -0.0001)},
+			true,
+			Silent,
+			SAMPLE_RATE / 2 * CHANNELS,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Silence{threshold: 0.001, trailing: time.Second * 2, deadAir: time.Second * 5, ending: tc.ending}
+			var samples []float32
+			var state SilenceState
+			for _, b := range tc.buffers {
+				samples, state = s.Process(b)
+			}
+			assert.Equal(t, tc.expected, state)
+			assert.Equal(t, tc.written, len(samples))
+		})
+	}
+}
+
+func TestSilenceStall(t *testing.T) {
+	s := &Silence{threshold: 0.001, trailing: time.Second * 2, deadAir: time.Second * 5}
+	s.Process(level(512, 0.2))
+	assert.Equal(t, Silent, s.Stall(time.Second*4))
+	assert.Equal(t, DeadAir, s.Stall(time.Second*6))
+	assert.Equal(t, Silent, s.Stall(time.Second*7), "dead air is reported once")
+	s.Process(level(512, 0.2))
+	assert.Equal(t, DeadAir, s.Stall(time.Second*5), "reported again after audio resumes")
+}
@@ -0,0 +1,112 @@
+// Local sound files, used for announcements
+
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/korandiz/mpa"
+)
+
+// Sound file errors
+var (
+	ErrUnsupportedSound = errors.New("unsupported sound file, must be .wav or .mp3")
+	ErrInvalidWAV       = errors.New("invalid wav file")
+	ErrWAVFormat        = errors.New("wav file must be 16 bit stereo PCM at 44100Hz")
+)
+
+// A decoded sound file
+type sound struct {
+	io.Reader
+	file *os.File
+}
+
+// Closes the sound file
+func (s *sound) Close() error {
+	return s.file.Close()
+}
+
+// Opens a local .wav or .mp3 file, returning a reader of 16 bit
+// little endian stereo PCM
+func OpenSound(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var r io.Reader
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		r = &mpa.Reader{Decoder: &mpa.Decoder{Input: f}}
+	case ".wav":
+		r, err = wavData(f)
+	default:
+		err = ErrUnsupportedSound
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &sound{r, f}, nil
+}
+
+// Reads a WAV header returning a reader of the sample data
+func wavData(r io.Reader) (io.Reader, error) {
+	var riff struct {
+		ID   [4]byte
+		Size uint32
+		Type [4]byte
+	}
+	if err := binary.Read(r, binary.LittleEndian, &riff); err != nil {
+		return nil, ErrInvalidWAV
+	}
+	if string(riff.ID[:]) != "RIFF" || string(riff.Type[:]) != "WAVE" {
+		return nil, ErrInvalidWAV
+	}
+	var format bool
+	for {
+		var chunk struct {
+			ID   [4]byte
+			Size uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &chunk); err != nil {
+			return nil, ErrInvalidWAV
+		}
+		switch string(chunk.ID[:]) {
+		case "fmt ":
+			var spec struct {
+				AudioFormat   uint16
+				Channels      uint16
+				SampleRate    uint32
+				ByteRate      uint32
+				BlockAlign    uint16
+				BitsPerSample uint16
+			}
+			if err := binary.Read(r, binary.LittleEndian, &spec); err != nil {
+				return nil, ErrInvalidWAV
+			}
+			if spec.AudioFormat != 1 || spec.Channels != CHANNELS ||
+				spec.SampleRate != SAMPLE_RATE || spec.BitsPerSample != 16 {
+				return nil, ErrWAVFormat
+			}
+			if _, err := io.CopyN(ioutil.Discard, r, int64(chunk.Size)-16); err != nil {
+				return nil, ErrInvalidWAV
+			}
+			format = true
+		case "data":
+			if !format {
+				return nil, ErrInvalidWAV
+			}
+			return io.LimitReader(r, int64(chunk.Size)), nil
+		default:
+			if _, err := io.CopyN(ioutil.Discard, r, int64(chunk.Size+chunk.Size%2)); err != nil {
+				return nil, ErrInvalidWAV
+			}
+		}
+	}
+}
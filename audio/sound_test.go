@@ -0,0 +1,114 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A WAV fmt chunk spec
+type wavSpec struct {
+	AudioFormat   uint16
+	Channels      uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+}
+
+var cdSpec = wavSpec{1, CHANNELS, SAMPLE_RATE, SAMPLE_RATE * CHANNELS * 2, CHANNELS * 2, 16}
+
+// Returns a RIFF chunk
+func wavChunk(id string, data []byte) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString(id)
+	binary.Write(b, binary.LittleEndian, uint32(len(data)))
+	b.Write(data)
+	if len(data)%2 == 1 {
+		b.WriteByte(0)
+	}
+	return b.Bytes()
+}
+
+// Returns a fmt chunk for a spec
+func wavFmt(spec wavSpec) []byte {
+	b := &bytes.Buffer{}
+	binary.Write(b, binary.LittleEndian, spec)
+	return wavChunk("fmt ", b.Bytes())
+}
+
+// Returns a WAV file of chunks
+func wavFile(chunks ...[]byte) []byte {
+	body := append([]byte("WAVE"), bytes.Join(chunks, nil)...)
+	return wavChunk("RIFF", body)
+}
+
+func TestWAVData(t *testing.T) {
+	pcm := []byte{1, 2, 3, 4}
+	tt := []struct {
+		tname string
+		input []byte
+		data  []byte
+		err   error
+	}{
+		{"valid", wavFile(wavFmt(cdSpec), wavChunk("data", pcm)), pcm, nil},
+		{"extra chunk", wavFile(wavFmt(cdSpec), wavChunk("LIST", []byte("odd")), wavChunk("data", pcm)), pcm, nil},
+		{"fmt extension", wavFile(wavChunk("fmt ", append(wavFmt(cdSpec)[8:], 0, 0)), wavChunk("data", pcm)), pcm, nil},
+		{"empty", nil, nil, ErrInvalidWAV},
+		{"not riff", append([]byte("RIFX"), wavFile(wavFmt(cdSpec))[4:]...), nil, ErrInvalidWAV},
+		{"not wave", wavChunk("RIFF", []byte("AVI ")), nil, ErrInvalidWAV},
+		{"no data", wavFile(wavFmt(cdSpec)), nil, ErrInvalidWAV},
+		{"data before fmt", wavFile(wavChunk("data", pcm), wavFmt(cdSpec)), nil, ErrInvalidWAV},
+		{"truncated fmt", wavFile(wavChunk("fmt ", []byte{1, 0})), nil, ErrInvalidWAV},
+		{"float", wavFile(wavFmt(wavSpec{3, CHANNELS, SAMPLE_RATE, 0, 0, 32})), nil, ErrWAVFormat},
+		{"mono", wavFile(wavFmt(wavSpec{1, 1, SAMPLE_RATE, 0, 0, 16})), nil, ErrWAVFormat},
+		{"sample rate", wavFile(wavFmt(wavSpec{1, CHANNELS, 48000, 0, 0, 16})), nil, ErrWAVFormat},
+		{"8 bit", wavFile(wavFmt(wavSpec{1, CHANNELS, SAMPLE_RATE, 0, 0, 8})), nil, ErrWAVFormat},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			r, err := wavData(bytes.NewReader(tc.input))
+			assert.Equal(t, tc.err, err)
+			if err != nil {
+				return
+			}
+			data, err := ioutil.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.data, data)
+		})
+	}
+}
+
+func TestOpenSound(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sound")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	tt := []struct {
+		tname string
+		name  string
+		data  []byte
+		err   error
+	}{
+		{"wav", "a.WAV", wavFile(wavFmt(cdSpec), wavChunk("data", nil)), nil},
+		{"invalid wav", "b.wav", []byte("nope"), ErrInvalidWAV},
+		{"unsupported", "c.ogg", nil, ErrUnsupportedSound},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name)
+			assert.NoError(t, ioutil.WriteFile(path, tc.data, 0644))
+			s, err := OpenSound(path)
+			assert.Equal(t, tc.err, err)
+			if err == nil {
+				assert.NoError(t, s.Close())
+			}
+		})
+	}
+}
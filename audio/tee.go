@@ -0,0 +1,273 @@
+// Tee output, writes the same samples to several outputs at once
+
+package audio
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"player/logger"
+)
+
+var (
+	ErrNoBranches = errors.New("no tee branches configured")
+	ErrTeeNested  = errors.New("tee branches cannot be tee outputs")
+)
+
+// Buffers queued for a branch when not configured
+const teeQueue = 8
+
+func init() {
+	Register("tee", Tee{})
+}
+
+// Tee output backend
+type Tee struct{}
+
+// Opens the output for each configured branch, the first branch must
+// open, other branches which fail to open are reopened in the background
+func (Tee) Open(c Configurer) (Output, error) {
+	branches, err := c.TeeBranches()
+	if err != nil {
+		return nil, err
+	}
+	if len(branches) == 0 {
+		return nil, ErrNoBranches
+	}
+	output := &TeeOutput{}
+	for i, config := range branches {
+		out, err := openTeeBranch(config, c)
+		if err != nil {
+			if i == 0 {
+				output.Close()
+				return nil, err
+			}
+			logger.WithError(err).WithField("backend", config.Backend).Warn("unable to open tee branch")
+		}
+		output.branches = append(output.branches, newTeeBranch(config, out, c, i == 0))
+	}
+	return output, nil
+}
+
+// Opens a branch output
+func openTeeBranch(config TeeBranch, c Configurer) (Output, error) {
+	if config.Backend == "tee" {
+		return nil, ErrTeeNested
+	}
+	backend, err := GetBackend(config.Backend)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Open(c)
+}
+
+// A single tee output
+type teeBranch struct {
+	config     TeeBranch
+	c          Configurer
+	outputLock *sync.Mutex
+	output     Output // Nil while a failed branch is reopened
+	gain       float32
+	delay      []float32      // Delay line, samples waiting to be written
+	latency    time.Duration  // Delay line length
+	queueC     chan []float32 // Queued samples, nil for the pacing branch
+	dropped    int            // Buffers dropped since the queue was last free
+	closeC     chan bool
+	closeWg    *sync.WaitGroup
+}
+
+// Applies the branch gain and delay to samples
+func (b *teeBranch) process(data []float32) []float32 {
+	line := append(b.delay, data...)
+	for i := range line[len(b.delay):] {
+		line[len(b.delay)+i] *= b.gain
+	}
+	out := make([]float32, len(data))
+	copy(out, line)
+	b.delay = line[len(data):]
+	return out
+}
+
+// Writes samples to the branch output, the pacing branch is written
+// directly and returns its error, other branches are queued and drop
+// samples when full
+func (b *teeBranch) Write(data []float32) error {
+	samples := b.process(data)
+	if b.queueC == nil {
+		_, err := b.output.Write(samples)
+		return err
+	}
+	select {
+	case b.queueC <- samples:
+		if b.dropped > 0 {
+			logger.WithFields(logger.F{
+				"backend": b.config.Backend,
+				"dropped": b.dropped,
+			}).Warn("tee branch recovered after dropping samples")
+			b.dropped = 0
+		}
+	default:
+		if b.dropped == 0 {
+			logger.WithField("backend", b.config.Backend).Warn("tee branch queue full, dropping samples")
+		}
+		b.dropped++
+	}
+	return nil
+}
+
+// Writes queued samples to the branch output, reopening it if it fails
+func (b *teeBranch) run() {
+	defer b.closeWg.Done()
+	if b.currentOutput() == nil && !b.reopen() {
+		return
+	}
+	for samples := range b.queueC {
+		_, err := b.currentOutput().Write(samples)
+		if err == nil {
+			continue
+		}
+		logger.WithError(err).WithField("backend", b.config.Backend).Error("tee branch write error")
+		output := b.setOutput(nil)
+		if err := output.Close(); err != nil {
+			logger.WithError(err).WithField("backend", b.config.Backend).Warn("error closing failed tee branch")
+		}
+		if !b.reopen() {
+			return
+		}
+	}
+}
+
+// Reopens the branch output, backing off between attempts, returns
+// false if the branch was closed before it was reopened
+func (b *teeBranch) reopen() bool {
+	backoff := b.c.RecoveryBackoff()
+	for {
+		logger.WithFields(logger.F{
+			"backend": b.config.Backend,
+			"retry":   backoff,
+		}).Warn("reopening tee branch")
+		select {
+		case <-b.closeC:
+			return false
+		case <-time.After(backoff):
+		}
+		output, err := openTeeBranch(b.config, b.c)
+		if err == nil {
+			b.setOutput(output)
+			logger.WithField("backend", b.config.Backend).Info("tee branch reopened")
+			return true
+		}
+		logger.WithError(err).WithField("backend", b.config.Backend).Warn("unable to open tee branch")
+		if backoff *= 2; backoff > b.c.RecoveryMax() {
+			backoff = b.c.RecoveryMax()
+		}
+	}
+}
+
+// Returns the branch output, nil while it is reopened
+func (b *teeBranch) currentOutput() Output {
+	b.outputLock.Lock()
+	defer b.outputLock.Unlock()
+	return b.output
+}
+
+// Replaces the branch output, returning the previous output
+func (b *teeBranch) setOutput(output Output) Output {
+	b.outputLock.Lock()
+	defer b.outputLock.Unlock()
+	old := b.output
+	b.output = output
+	return old
+}
+
+// Stops writing and closes the branch output
+func (b *teeBranch) Close() error {
+	if b.queueC != nil {
+		close(b.closeC)
+		close(b.queueC)
+		b.closeWg.Wait()
+	}
+	if output := b.currentOutput(); output != nil {
+		return output.Close()
+	}
+	return nil
+}
+
+// Constructs a new tee branch, the pacing branch is written directly,
+// others from their own goroutine, a branch without an output is
+// opened in the background
+func newTeeBranch(config TeeBranch, output Output, c Configurer, pacing bool) *teeBranch {
+	b := &teeBranch{
+		config:     config,
+		c:          c,
+		outputLock: &sync.Mutex{},
+		output:     output,
+		gain:       float32(math.Pow(10, config.Volume/20)),
+		delay:      make([]float32, int(config.Delay.Seconds()*SAMPLE_RATE+0.5)*CHANNELS),
+		closeC:     make(chan bool),
+		closeWg:    &sync.WaitGroup{},
+	}
+	b.latency = samplesDuration(len(b.delay))
+	if !pacing {
+		queue := config.Queue
+		if queue <= 0 {
+			queue = teeQueue
+		}
+		b.queueC = make(chan []float32, queue)
+		b.closeWg.Add(1)
+		go b.run()
+	}
+	return b
+}
+
+// Writes samples to several outputs
+type TeeOutput struct {
+	branches []*teeBranch
+}
+
+// Writes samples to all branches, blocking only on the first branch,
+// returns the first branch's error so it paces and recovers playback
+func (output *TeeOutput) Write(data []float32) (int, error) {
+	var err error
+	for i, b := range output.branches {
+		if berr := b.Write(data); i == 0 {
+			err = berr
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Sets the now playing title on branches which support metadata
+func (output *TeeOutput) SetMetadata(title string) {
+	for _, b := range output.branches {
+		if m, ok := b.currentOutput().(Metadataer); ok {
+			m.SetMetadata(title)
+		}
+	}
+}
+
+// Returns the latency of the first branch, which paces playback
+func (output *TeeOutput) Latency() time.Duration {
+	b := output.branches[0]
+	latency := b.latency
+	if l, ok := b.output.(Latencyer); ok {
+		latency += l.Latency()
+	}
+	return latency
+}
+
+// Closes all branches
+func (output *TeeOutput) Close() error {
+	var err error
+	for _, b := range output.branches {
+		if cerr := b.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package audio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+type testOutput struct {
+	written [][]float32
+}
+
+func (o *testOutput) Write(data []float32) (int, error) {
+	o.written = append(o.written, data)
+	return len(data), nil
+}
+
+func (o *testOutput) Close() error {
+	return nil
+}
+
+func TestTeeBranchDelay(t *testing.T) {
+	out := &testOutput{}
+	// One frame of delay at half volume
+	delay := time.Second / SAMPLE_RATE
+	b := newTeeBranch(TeeBranch{Volume: -6.0206, Delay: delay}, out, NewConfig(), true)
+	in := []float32{1, 1, 0.5, 0.5}
+	b.Write(in)
+	b.Write([]float32{0, 0})
+	assert.Equal(t, []float32{1, 1, 0.5, 0.5}, in) // Input is not modified
+	assert.Len(t, out.written, 2)
+	assert.Equal(t, []float32{0, 0, 0.5, 0.5}, round(out.written[0]))
+	assert.Equal(t, []float32{0.25, 0.25}, round(out.written[1]))
+}
+
+func TestTeeDropsWhenQueueFull(t *testing.T) {
+	pacing := &testOutput{}
+	blocked := &blockedOutput{C: make(chan bool)}
+	output := &TeeOutput{branches: []*teeBranch{
+		newTeeBranch(TeeBranch{}, pacing, NewConfig(), true),
+		newTeeBranch(TeeBranch{Queue: 1}, blocked, NewConfig(), false),
+	}}
+	for i := 0; i < 10; i++ {
+		n, err := output.Write([]float32{0, 0})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+	}
+	// The pacing branch gets everything, the blocked branch never stalls it
+	assert.Len(t, pacing.written, 10)
+	assert.True(t, output.branches[1].dropped > 0)
+	close(blocked.C)
+	assert.NoError(t, output.Close())
+}
+
+type failedOutput struct{}
+
+func (failedOutput) Write(data []float32) (int, error) { return 0, ErrOutputFailed }
+func (failedOutput) Close() error                      { return nil }
+
+func TestTeeReturnsPacingError(t *testing.T) {
+	output := &TeeOutput{branches: []*teeBranch{
+		newTeeBranch(TeeBranch{}, failedOutput{}, NewConfig(), true),
+	}}
+	n, err := output.Write([]float32{0, 0})
+	assert.Equal(t, ErrOutputFailed, err)
+	assert.Equal(t, 0, n)
+	assert.NoError(t, output.Close())
+}
+
+// Opens the outputs sent to it
+type teeTestBackend chan Output
+
+func (b teeTestBackend) Open(c Configurer) (Output, error) {
+	return <-b, nil
+}
+
+func TestTeeReopensFailedBranch(t *testing.T) {
+	viper.Set("audio.recovery.backoff", "10ms")
+	defer viper.Set("audio.recovery.backoff", "1s")
+	backend := make(teeTestBackend, 1)
+	Register("teetest", backend)
+	reopened := &chanOutput{make(chan []float32, 8)}
+	backend <- reopened
+	output := &TeeOutput{branches: []*teeBranch{
+		newTeeBranch(TeeBranch{}, &testOutput{}, NewConfig(), true),
+		newTeeBranch(TeeBranch{Backend: "teetest"}, failedOutput{}, NewConfig(), false),
+	}}
+	timeout := time.After(time.Second)
+	for {
+		_, err := output.Write([]float32{0, 0})
+		assert.NoError(t, err)
+		select {
+		case samples := <-reopened.C:
+			assert.Equal(t, []float32{0, 0}, samples)
+			assert.NoError(t, output.Close())
+			return
+		case <-timeout:
+			t.Fatal("failed tee branch not reopened")
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+}
+
+// Sends samples written to it on a channel
+type chanOutput struct {
+	C chan []float32
+}
+
+func (o *chanOutput) Write(data []float32) (int, error) {
+	select {
+	case o.C <- data:
+	default:
+	}
+	return len(data), nil
+}
+
+func (o *chanOutput) Close() error {
+	return nil
+}
+
+type blockedOutput struct {
+	C chan bool
+}
+
+func (o *blockedOutput) Write(data []float32) (int, error) {
+	<-o.C
+	return len(data), nil
+}
+
+func (o *blockedOutput) Close() error {
+	return nil
+}
+
+// Rounds samples to 3 decimal places
+func round(s []float32) []float32 {
+	out := make([]float32, len(s))
+	for i, v := range s {
+		out[i] = float32(int(v*1000+0.5)) / 1000
+	}
+	return out
+}
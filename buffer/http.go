@@ -4,11 +4,14 @@ package buffer
 
 import (
 	"bufio"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"time"
 
 	"player/logger"
+	"player/tags"
 
 	"github.com/djherbis/buffer"
 )
@@ -21,21 +24,92 @@ type HTTP struct {
 	file     *os.File        // Buffer temporary file
 	buffer   buffer.BufferAt // Internal Buffer
 	buffered int             // Amount buffered
+	err      error           // Why buffering failed, set before doneC is closed
+	doneC    chan bool       // Closed once buffering has finished
+	// Tag parsing
+	tags  *tags.Tags     // Parsed tags, nil if the stream has none
+	tagsW *io.PipeWriter // Buffered data is copied here for tag parsing
+	tagsC chan bool      // Closed once tag parsing is complete
+}
+
+// Parses tags from the data written to the tags pipe
+func (h *HTTP) parseTags(r *io.PipeReader) {
+	defer close(h.tagsC)
+	t, err := tags.Read(r)
+	r.Close() // Stops further copying to the pipe
+	if err != nil {
+		logger.WithError(err).Debug("no tags read from http stream")
+		return
+	}
+	h.tags = t
+}
+
+// Returns the stream tags, waiting up to timeout for them to be parsed,
+// returns nil if the stream has no tags or the timeout was reached
+func (h *HTTP) Tags(timeout time.Duration) *tags.Tags {
+	select {
+	case <-h.tagsC:
+		return h.tags
+	case <-time.After(timeout):
+		return nil
+	}
+}
+
+// Returns true once buffering has finished
+func (h *HTTP) done() bool {
+	select {
+	case <-h.doneC:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns why buffering failed, nil while buffering or if the whole
+// stream was buffered
+func (h *HTTP) Err() error {
+	if !h.done() {
+		return nil
+	}
+	return h.err
+}
+
+// Returns the bytes left to read from the buffer and true once buffering
+// has finished
+func (h *HTTP) Remaining() (int, bool) {
+	if !h.done() {
+		return 0, false
+	}
+	if h.buffer == nil {
+		return 0, true
+	}
+	return int(h.buffer.Len()), true
 }
 
 // Read from the buffer
 func (h *HTTP) Read(b []byte) (int, error) {
+	done := h.done()
 	// If we try and read before we have a buffer return an error
 	// that we don't yet have a buffer
 	if h.buffer == nil {
+		if done && h.err != nil {
+			return 0, h.err
+		}
 		return 0, io.ErrShortBuffer
 	}
 	// Wait for buffer to fill
-	if h.buffered < 32*1024 {
+	if h.buffered < 32*1024 && !done {
 		return 0, io.ErrShortBuffer
 	}
-	// If we are at the end of the buffer return EOF
+	// At the end of the buffer wait for more unless buffering has
+	// finished, then return EOF or why buffering failed
 	if h.buffer.Len() == 0 {
+		if !done {
+			return 0, io.ErrShortBuffer
+		}
+		if h.err != nil {
+			return 0, h.err
+		}
 		return 0, io.EOF
 	}
 	// Read from the buffer
@@ -57,12 +131,21 @@ func (h *HTTP) Close() error {
 	return nil
 }
 
-// Buffer the http Response into a temporary file for reading
-func (h *HTTP) Buffer() error {
+// Buffer the http Response into a temporary file for reading, the
+// error is also returned from Read once the buffer has been read
+func (h *HTTP) Buffer() (err error) {
+	defer func() {
+		h.err = err
+		close(h.doneC)
+	}()
 	f := logger.F{"size": h.Response.ContentLength}
 	logger.WithFields(f).Debug("start http buffer")
 	defer logger.WithFields(f).Debug("finished buffering")
 	defer h.Response.Body.Close() // Close the HTTP Response body once we are done
+	defer h.tagsW.Close()         // Signals the end of stream to the tag parser
+	if h.Response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("stream request failed: %s", h.Response.Status)
+	}
 	// Make the buffer
 	file, buff, err := Make(h.Response.ContentLength)
 	if err != nil {
@@ -70,6 +153,7 @@ func (h *HTTP) Buffer() error {
 	}
 	h.file = file
 	h.buffer = buff
+	tagging := true
 	var eof bool
 	data := make([]byte, 1024*8) // Read response data into here
 	writer := bufio.NewWriter(h.buffer)
@@ -92,6 +176,12 @@ func (h *HTTP) Buffer() error {
 			return err
 		}
 		h.buffered += wn
+		// Copy to the tag parser until it has what it needs
+		if tagging {
+			if _, err := h.tagsW.Write(data[:rn]); err != nil {
+				tagging = false
+			}
+		}
 		if eof {
 			return nil
 		}
@@ -100,7 +190,13 @@ func (h *HTTP) Buffer() error {
 
 // Construct a new HTTP Buffer for a HTTP Response
 func HTTPBuffer(rsp *http.Response) *HTTP {
-	return &HTTP{
+	r, w := io.Pipe()
+	h := &HTTP{
 		Response: rsp,
+		tagsW:    w,
+		tagsC:    make(chan bool),
+		doneC:    make(chan bool),
 	}
+	go h.parseTags(r)
+	return h
 }
@@ -17,12 +17,43 @@ type Spotify struct {
 	buffer   buffer.Buffer    // Internal Buffer
 	buffered int              // Amount buffered
 	session  *spotify.Session // Spotify Session
+	err      error            // Why buffering failed, set before doneC is closed
+	doneC    chan bool        // Closed once buffering has finished
 	wg       sync.WaitGroup
 	closeC   chan bool
 }
 
+// Returns why buffering failed, nil while buffering or if the whole
+// track was buffered
+func (s *Spotify) Err() error {
+	select {
+	case <-s.doneC:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Returns the bytes left to read from the buffer and true once the
+// whole track has been buffered
+func (s *Spotify) Remaining() (int, bool) {
+	select {
+	case <-s.doneC:
+	default:
+		return 0, false
+	}
+	if s.buffer == nil {
+		return 0, true
+	}
+	return int(s.buffer.Len()), true
+}
+
 // Read from the buffer
 func (s *Spotify) Read(b []byte) (int, error) {
+	// A track which failed to load never fills the buffer
+	if err := s.Err(); err != nil && s.buffered == 0 {
+		return 0, err
+	}
 	// If we try and read before we have a buffer return an error
 	// that we don't yet have a buffer
 	if s.buffer == nil {
@@ -63,9 +94,15 @@ func (s *Spotify) WriteAudio(f spotify.AudioFormat, raw []byte) int {
 	}
 }
 
-func (s *Spotify) Buffer(track *spotify.Track) error {
+// Buffers a track until it ends or the buffer is closed, the error is
+// also returned from Read
+func (s *Spotify) Buffer(track *spotify.Track) (err error) {
 	s.wg.Add(1)
 	defer s.wg.Done()
+	defer func() {
+		s.err = err
+		close(s.doneC)
+	}()
 	logger.Debug("start spotify buffer")
 	defer logger.Debug("exit spotify buffer")
 	// Buffer to memory for spotify, results in smoother playback
@@ -93,6 +130,7 @@ func (s *Spotify) Buffer(track *spotify.Track) error {
 func SpotifyBuffer(session *spotify.Session) *Spotify {
 	return &Spotify{
 		session: session,
+		doneC:   make(chan bool),
 		closeC:  make(chan bool),
 	}
 }
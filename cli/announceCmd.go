@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"player/event"
+	"player/run"
+	"player/sockets/unix"
+
+	"github.com/rs/xid"
+	"github.com/spf13/cobra"
+)
+
+var (
+	announceCmdProviderName    string
+	announceCmdProviderTrackID string
+	announceCmdFile            string
+)
+
+var announceCmd = &cobra.Command{
+	Use:   "announce",
+	Short: "Play an announcement over the current track",
+	Run: func(cmd *cobra.Command, args []string) {
+		defer fmt.Println("Done")
+		config := unix.NewConfig()
+		client := unix.NewClient()
+		if err := client.Connect(config.Address()); err != nil {
+			fmt.Println("Unable to connect to player:", err)
+			return
+		}
+		defer client.Close()
+		if announceCmdFile == "" && (announceCmdProviderName == "" || announceCmdProviderTrackID == "") {
+			fmt.Println("Need a sound file or a provider and a track ID")
+			return
+		}
+		if announceCmdFile != "" {
+			// The player may not share our working directory
+			path, err := filepath.Abs(announceCmdFile)
+			if err != nil {
+				fmt.Println("Invalid sound file path:", err)
+				return
+			}
+			announceCmdFile = path
+		}
+		payload, err := json.Marshal(&event.AnnouncePayload{
+			ProviderName:    announceCmdProviderName,
+			ProviderTrackID: announceCmdProviderTrackID,
+			File:            announceCmdFile,
+		})
+		if err != nil {
+			fmt.Println("Unable to create announce payload:", err)
+			return
+		}
+		// Replies to this event carry its id
+		id := xid.New().String()
+		body, err := json.Marshal(&event.Event{
+			V:       event.Version,
+			ID:      id,
+			Topic:   event.AnnounceEvent,
+			Created: time.Now().UTC(),
+			Payload: json.RawMessage(payload),
+		})
+		if err != nil {
+			fmt.Println("Unable to create announce event:", err)
+			return
+		}
+		fmt.Println("Announcing...")
+		if _, err := client.Write(body); err != nil {
+			fmt.Println("Unable to send announce event:", err)
+			return
+		}
+		// The player only replies on error
+		exitC := make(chan bool)
+		go func() {
+			defer close(exitC)
+			for {
+				b, err := client.Read()
+				if err != nil {
+					return
+				}
+				e := &event.Event{}
+				if err := json.Unmarshal(b, e); err != nil {
+					fmt.Println("error reading event:", err)
+				}
+				if e.ReplyTo != id {
+					continue // Not a reply to this command
+				}
+				switch e.Topic {
+				case event.ErrorEvent:
+					payload := &event.ErrorPayload{}
+					if err := json.Unmarshal(e.Payload, payload); err != nil {
+						fmt.Println("Unable to process error")
+					}
+					fmt.Println("Error announcing:", payload.Error)
+					return
+				}
+			}
+		}()
+		deadline := time.Second
+		select {
+		case <-exitC:
+			return
+		case <-run.UntilQuit():
+			return
+		case <-time.After(deadline):
+			return
+		}
+	},
+}
+
+func init() {
+	announceCmd.PersistentFlags().StringVarP(
+		&announceCmdProviderName,
+		"providerName",
+		"n",
+		"",
+		"Track Provider Name (googlemusic, soundcloud etc)")
+	announceCmd.PersistentFlags().StringVarP(
+		&announceCmdProviderTrackID,
+		"providerTrackID",
+		"t",
+		"",
+		"Provider Track ID")
+	announceCmd.PersistentFlags().StringVarP(
+		&announceCmdFile,
+		"file",
+		"f",
+		"",
+		"Local .wav or .mp3 sound file")
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"fmt"
+
+	"player/audio"
+
+	"github.com/spf13/cobra"
+)
+
+var devicesCmdBackend string
+
+var devicesCmd = &cobra.Command{
+	Use:   "devices",
+	Short: "List the output devices of an audio backend",
+	Run: func(cmd *cobra.Command, args []string) {
+		backend := devicesCmdBackend
+		if backend == "" {
+			backend = audio.NewConfig().Backend()
+		}
+		devices, err := audio.Devices(backend)
+		if err != nil {
+			fmt.Println("Unable to list devices:", err)
+			return
+		}
+		fmt.Printf("%s devices:\n", backend)
+		for _, d := range devices {
+			def := ""
+			if d.Default {
+				def = " (default)"
+			}
+			fmt.Printf("%4d  %s%s\n", d.Index, d.Name, def)
+		}
+	},
+}
+
+func init() {
+	devicesCmd.PersistentFlags().StringVarP(
+		&devicesCmdBackend,
+		"backend",
+		"b",
+		"",
+		"Audio backend, defaults to the configured backend")
+}
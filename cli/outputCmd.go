@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"player/event"
+	"player/run"
+	"player/sockets/unix"
+
+	"github.com/rs/xid"
+	"github.com/spf13/cobra"
+)
+
+var (
+	outputCmdBackend string
+	outputCmdDevice  string
+)
+
+var outputCmd = &cobra.Command{
+	Use:   "output",
+	Short: "Switch the audio output backend or device",
+	Run: func(cmd *cobra.Command, args []string) {
+		defer fmt.Println("Done")
+		config := unix.NewConfig()
+		client := unix.NewClient()
+		if err := client.Connect(config.Address()); err != nil {
+			fmt.Println("Unable to connect to player:", err)
+			return
+		}
+		defer client.Close()
+		payload, err := json.Marshal(&event.OutputPayload{
+			Backend: outputCmdBackend,
+			Device:  outputCmdDevice,
+		})
+		if err != nil {
+			fmt.Println("Unable to create output payload:", err)
+			return
+		}
+		// Replies to this event carry its id
+		id := xid.New().String()
+		body, err := json.Marshal(&event.Event{
+			V:       event.Version,
+			ID:      id,
+			Topic:   event.OutputEvent,
+			Created: time.Now().UTC(),
+			Payload: json.RawMessage(payload),
+		})
+		if err != nil {
+			fmt.Println("Unable to create output event:", err)
+			return
+		}
+		fmt.Println("Switching output...")
+		if _, err := client.Write(body); err != nil {
+			fmt.Println("Unable to send output event:", err)
+			return
+		}
+		exitC := make(chan bool)
+		go func() {
+			defer close(exitC)
+			for {
+				b, err := client.Read()
+				if err != nil {
+					return
+				}
+				e := &event.Event{}
+				if err := json.Unmarshal(b, e); err != nil {
+					fmt.Println("error reading event:", err)
+				}
+				if e.ReplyTo != id {
+					continue // Not a reply to this command
+				}
+				switch e.Topic {
+				case event.OutputChangedEvent:
+					payload := &event.OutputPayload{}
+					if err := json.Unmarshal(e.Payload, payload); err != nil {
+						fmt.Println("Unable to process output changed event")
+					}
+					fmt.Printf("Output switched to %s %s\n", payload.Backend, payload.Device)
+					return
+				case event.ErrorEvent:
+					payload := &event.ErrorPayload{}
+					if err := json.Unmarshal(e.Payload, payload); err != nil {
+						fmt.Println("Unable to process error")
+					}
+					fmt.Println("Error switching output:", payload.Error)
+					return
+				}
+			}
+		}()
+		deadline := time.Second * 5
+		select {
+		case <-exitC:
+			return
+		case <-run.UntilQuit():
+			return
+		case <-time.After(deadline):
+			fmt.Println("Timed out waiting for the player")
+			return
+		}
+	},
+}
+
+func init() {
+	outputCmd.PersistentFlags().StringVarP(
+		&outputCmdBackend,
+		"backend",
+		"b",
+		"",
+		"Audio backend, defaults to the current backend")
+	outputCmd.PersistentFlags().StringVarP(
+		&outputCmdDevice,
+		"device",
+		"d",
+		"",
+		"Device name or index, defaults to the default device")
+}
@@ -47,7 +47,11 @@ var playCmd = &cobra.Command{
 			fmt.Println("Unable to create play payload:", err)
 			return
 		}
+		// Replies to this event carry its id
+		id := xid.New().String()
 		body, err := json.Marshal(&event.Event{
+			V:       event.Version,
+			ID:      id,
 			Topic:   event.PlayEvent,
 			Created: time.Now().UTC(),
 			Payload: json.RawMessage(payload),
@@ -72,10 +76,20 @@ var playCmd = &cobra.Command{
 				if err := json.Unmarshal(b, e); err != nil {
 					fmt.Println("error reading event:", err)
 				}
+				if e.ReplyTo != id {
+					continue // Not a reply to this command
+				}
 				switch e.Topic {
 				case event.PlayingEvent:
 					fmt.Println("Playing track")
 					return
+				case event.FailedEvent:
+					payload := &event.LifecyclePayload{}
+					if err := json.Unmarshal(e.Payload, payload); err != nil {
+						fmt.Println("Unable to process failure")
+					}
+					fmt.Println("Track failed:", payload.Reason)
+					return
 				case event.ErrorEvent:
 					payload := &event.ErrorPayload{}
 					if err := json.Unmarshal(e.Payload, payload); err != nil {
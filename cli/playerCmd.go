@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"time"
 
+	"player/artwork"
 	"player/audio"
 	"player/config"
 	"player/event"
 	"player/logger"
+	"player/multiroom"
 	"player/player"
 	"player/providers/googlemusic"
 	"player/providers/soundcloud"
@@ -44,6 +46,24 @@ var playerCmd = &cobra.Command{
 			return
 		}
 		defer audio.Close()
+		// Level and spectrum analysis for visualisers, published
+		// on a separate socket to the event hub
+		if ac := audio.NewConfig(); ac.AnalyzerEnabled() {
+			levelsock := unix.NewPublisher(unix.NewConfig().LevelsAddress())
+			if err := levelsock.Listen(); err != nil {
+				logger.WithError(err).Error("failed to open levels socket")
+			}
+			defer levelsock.Close()
+			analyzer := audio.NewAnalyzer(ac)
+			audio.AddTap(analyzer)
+			analyzer.Start()
+			defer analyzer.Close()
+			go event.PublishLevels(analyzer.Levels(), levelsock)
+		}
+		// Artwork server for pictures embedded in track tags
+		artworksrv := artwork.NewServer(artwork.NewConfig())
+		go artworksrv.Listen()
+		defer artworksrv.Close()
 		// Google Music Provider
 		gmp, err := googlemusic.New(googlemusic.NewConfig())
 		if err != nil {
@@ -64,17 +84,44 @@ var playerCmd = &cobra.Command{
 		player.AddProvider(sp)
 		// Close the player on exit
 		defer player.Close()
-		// Event Hub
+		// Multi-room playback, leading or following other players
+		if err := multiroom.Start(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer multiroom.Close()
+		// Event Hub, journaling events for clients to replay
+		ec := event.NewConfig()
+		if err := event.OpenJournal(ec); err != nil {
+			fmt.Println(err)
+			return
+		}
+		// Audited before rate limiting so rejected events are logged
+		if ec.Audit() {
+			event.Use(event.Audit())
+			event.UseOutbound(event.AuditOutbound())
+		}
+		if ec.RateLimit() > 0 {
+			event.Use(event.RateLimit(ec.RateLimit(), ec.RateBurst()))
+		}
 		go event.ProcessEvents()
 		defer event.Close()
-		// Websocket Client
-		websocket := web.New(web.NewConfig())
-		go websocket.Connect()
-		defer websocket.Close()
 		// Start a unix socket server for IPC
 		unixsock := unix.NewServer(unix.NewConfig())
 		go unixsock.Listen()
 		defer unixsock.Close()
+		// Followers take commands from their leader, not the websocket
+		if multiroom.Following() {
+			logger.Debug("application setup complete")
+			sig := <-run.UntilQuit()
+			logger.WithField("sig", sig).Debug("received os signal")
+			return
+		}
+		// Websocket Client
+		websocket := web.New(web.NewConfig())
+		event.AddStatus("websocket", func() interface{} { return websocket.Stats() })
+		go websocket.Connect()
+		defer websocket.Close()
 		// Finally send the player.ready event - block until connected
 		for !websocket.Connected() {
 			<-time.After(time.Second)
@@ -103,7 +150,7 @@ func init() {
 		"c",
 		"",
 		"Optional absolute path to toml config file")
-	playerCmd.AddCommand(buildCmd, playCmd, stopCmd, pauseCmd, resumeCmd)
+	playerCmd.AddCommand(buildCmd, playCmd, stopCmd, pauseCmd, resumeCmd, announceCmd, devicesCmd, outputCmd, receiveCmd, schemaCmd)
 }
 
 func Run() error {
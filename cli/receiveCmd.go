@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+
+	"player/audio"
+	"player/logger"
+	"player/run"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var receiveCmdBackend string
+
+var receiveCmd = &cobra.Command{
+	Use:   "receive",
+	Short: "Play an rtp stream from another player through the local audio backend",
+	Run: func(cmd *cobra.Command, args []string) {
+		defer logger.Info("exit")
+		if receiveCmdBackend != "" {
+			viper.Set("audio.backend", receiveCmdBackend)
+		}
+		config := audio.NewConfig()
+		if config.Backend() == "rtp" {
+			fmt.Println("Cannot receive with the rtp backend, choose a local backend")
+			return
+		}
+		if err := audio.Open(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer audio.Close()
+		output, err := audio.Main()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		receiver, err := audio.Receive(config, output)
+		if err != nil {
+			fmt.Println("Unable to receive rtp stream:", err)
+			return
+		}
+		defer receiver.Close()
+		logger.WithField("address", config.RTPAddress()).Info("receiving rtp stream")
+		sig := <-run.UntilQuit()
+		logger.WithField("sig", sig).Debug("received os signal")
+	},
+}
+
+func init() {
+	receiveCmd.PersistentFlags().StringVarP(
+		&receiveCmdBackend,
+		"backend",
+		"b",
+		"",
+		"Audio backend to play through, defaults to the configured backend")
+}
@@ -9,6 +9,7 @@ import (
 	"player/run"
 	"player/sockets/unix"
 
+	"github.com/rs/xid"
 	"github.com/spf13/cobra"
 )
 
@@ -24,7 +25,11 @@ var resumeCmd = &cobra.Command{
 			return
 		}
 		defer client.Close()
+		// Replies to this event carry its id
+		id := xid.New().String()
 		eb, err := json.Marshal(&event.Event{
+			V:       event.Version,
+			ID:      id,
 			Topic:   event.ResumeEvent,
 			Created: time.Now().UTC(),
 		})
@@ -49,8 +54,11 @@ var resumeCmd = &cobra.Command{
 				if err := json.Unmarshal(b, e); err != nil {
 					fmt.Println("error reading event:", err)
 				}
+				if e.ReplyTo != id {
+					continue // Not a reply to this command
+				}
 				switch e.Topic {
-				case event.PlayingEvent:
+				case event.ResumedEvent:
 					fmt.Println("Playback resumed")
 					return
 				case event.ErrorEvent:
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"player/event"
+
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema [topic]",
+	Short: "Print the JSON Schemas of the event envelope and topic payloads",
+	Run: func(cmd *cobra.Command, args []string) {
+		var b []byte
+		var err error
+		if len(args) > 0 {
+			s, ok := event.Schema(args[0])
+			if !ok {
+				fmt.Println("Unknown topic:", args[0])
+				return
+			}
+			buf := &bytes.Buffer{}
+			err = json.Indent(buf, s, "", "  ")
+			b = buf.Bytes()
+		} else {
+			b, err = json.MarshalIndent(event.Schemas(), "", "  ")
+		}
+		if err != nil {
+			fmt.Println("Unable to print schema:", err)
+			return
+		}
+		fmt.Println(string(b))
+	},
+}
@@ -9,6 +9,7 @@ import (
 	"player/run"
 	"player/sockets/unix"
 
+	"github.com/rs/xid"
 	"github.com/spf13/cobra"
 )
 
@@ -24,7 +25,11 @@ var stopCmd = &cobra.Command{
 			return
 		}
 		defer client.Close()
+		// Replies to this event carry its id
+		id := xid.New().String()
 		eb, err := json.Marshal(&event.Event{
+			V:       event.Version,
+			ID:      id,
 			Topic:   event.StopEvent,
 			Created: time.Now().UTC(),
 		})
@@ -49,6 +54,9 @@ var stopCmd = &cobra.Command{
 				if err := json.Unmarshal(b, e); err != nil {
 					fmt.Println("error reading event:", err)
 				}
+				if e.ReplyTo != id {
+					continue // Not a reply to this command
+				}
 				switch e.Topic {
 				case event.StoppedEvent:
 					fmt.Println("Playback stopped")
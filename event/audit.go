@@ -0,0 +1,50 @@
+package event
+
+import (
+	"player/logger"
+)
+
+// Logs every event received by the hub with the client which sent it
+// and the outcome of handling it
+func Audit() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ce ClientEvent) error {
+			err := next(ce)
+			log := logger.WithFields(logger.F{
+				"client": ce.Client.ID(),
+				"topic":  ce.Event.Topic,
+				"id":     ce.Event.ID,
+			})
+			if err != nil {
+				log = log.WithError(err)
+			}
+			log.Info("audit event received")
+			return err
+		}
+	}
+}
+
+// Logs every event emitted by the hub and the client it was written to,
+// broadcasts have no client
+func AuditOutbound() OutboundMiddleware {
+	return func(next EmitFunc) EmitFunc {
+		return func(e Event, to Client) error {
+			err := next(e, to)
+			client := ""
+			if to != nil {
+				client = to.ID()
+			}
+			log := logger.WithFields(logger.F{
+				"client":  client,
+				"topic":   e.Topic,
+				"replyTo": e.ReplyTo,
+				"seq":     e.Seq,
+			})
+			if err != nil {
+				log = log.WithError(err)
+			}
+			log.Info("audit event emitted")
+			return err
+		}
+	}
+}
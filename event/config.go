@@ -0,0 +1,96 @@
+package event
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	vJournalPath = "journal.path"
+	vJournalSize = "journal.size"
+	vHubBuffer   = "hub.buffer"
+	vHubTimeout  = "hub.timeout"
+	vHubAudit    = "hub.audit"
+	vHubRate     = "hub.ratelimit.rate"
+	vHubBurst    = "hub.ratelimit.burst"
+	vHubHold     = "hub.hold"
+)
+
+func init() {
+	viper.SetDefault(vJournalPath, "/tmp/sfmplayer.journal")
+	viper.SetDefault(vJournalSize, 1000)
+	viper.SetDefault(vHubBuffer, 256)
+	viper.SetDefault(vHubTimeout, "5s")
+	viper.SetDefault(vHubAudit, false)
+	viper.SetDefault(vHubRate, 0)
+	viper.SetDefault(vHubBurst, 10)
+	viper.SetDefault(vHubHold, 0)
+	viper.BindEnv(
+		vJournalPath,
+		vJournalSize,
+		vHubBuffer,
+		vHubTimeout,
+		vHubAudit,
+		vHubRate,
+		vHubBurst,
+		vHubHold)
+}
+
+type Configurer interface {
+	JournalPath() string
+	JournalSize() int
+	HubBuffer() int
+	HubTimeout() time.Duration
+	Audit() bool
+	RateLimit() float64
+	RateBurst() int
+	HubHold() time.Duration
+}
+
+type Config struct{}
+
+// Path of the event journal, empty to keep events in memory only
+func (c Config) JournalPath() string {
+	return viper.GetString(vJournalPath)
+}
+
+// Number of events kept in the journal for replay
+func (c Config) JournalSize() int {
+	return viper.GetInt(vJournalSize)
+}
+
+// Events buffered per client before the client is disconnected
+func (c Config) HubBuffer() int {
+	return viper.GetInt(vHubBuffer)
+}
+
+// Time allowed to write an event to a client before it is disconnected
+func (c Config) HubTimeout() time.Duration {
+	return viper.GetDuration(vHubTimeout)
+}
+
+// Log every event received and emitted by the hub
+func (c Config) Audit() bool {
+	return viper.GetBool(vHubAudit)
+}
+
+// Events per second each client may send, 0 disables rate limiting
+func (c Config) RateLimit() float64 {
+	return viper.GetFloat64(vHubRate)
+}
+
+// Events a client may send at once before it is rate limited
+func (c Config) RateBurst() int {
+	return viper.GetInt(vHubBurst)
+}
+
+// Time live events are held for a new client until its first event so
+// the events it replays are written first, 0 disables holding
+func (c Config) HubHold() time.Duration {
+	return viper.GetDuration(vHubHold)
+}
+
+func NewConfig() Config {
+	return Config{}
+}
@@ -0,0 +1,89 @@
+package event
+
+import (
+	"os"
+
+	"player/audio"
+	"player/multiroom"
+	"player/player"
+)
+
+// Error codes sent in player:error events, stable for clients to match
+// on rather than the error message
+const (
+	CodeAlreadyPlaying       = "ALREADY_PLAYING"
+	CodeInvalidState         = "INVALID_STATE"
+	CodeInvalidPayload       = "INVALID_PAYLOAD"
+	CodeUnknownProvider      = "UNKNOWN_PROVIDER"
+	CodeProviderStreamFailed = "PROVIDER_STREAM_FAILED"
+	CodeDecodeFailed         = "DECODE_FAILED"
+	CodeOutputFailed         = "OUTPUT_FAILED"
+	CodeUnknownOutput        = "UNKNOWN_OUTPUT"
+	CodeFileNotFound         = "FILE_NOT_FOUND"
+	CodeFollowing            = "FOLLOWING"
+	CodeUnsupportedVersion   = "UNSUPPORTED_VERSION"
+	CodeRateLimited          = "RATE_LIMITED"
+	CodeUnknownTopic         = "UNKNOWN_TOPIC"
+	CodeInternal             = "INTERNAL"
+)
+
+// An error code and whether the request may succeed if retried
+type errorCode struct {
+	code      string
+	retryable bool
+}
+
+// Codes of errors which can be returned to clients
+var errorCodes = map[error]errorCode{
+	player.ErrPlaying:         {CodeAlreadyPlaying, true},
+	ErrPausng:                 {CodeInvalidState, false},
+	ErrResuming:               {CodeInvalidState, false},
+	ErrStopping:               {CodeInvalidState, false},
+	player.ErrUnknownProvider: {CodeUnknownProvider, false},
+	player.ErrStreamFailed:    {CodeProviderStreamFailed, true},
+	player.ErrDecodeFailed:    {CodeDecodeFailed, false},
+	player.ErrOutputFailed:    {CodeOutputFailed, true},
+	audio.ErrNoOutput:         {CodeOutputFailed, true},
+	audio.ErrOutputFailed:     {CodeOutputFailed, true},
+	audio.ErrNoBackend:        {CodeOutputFailed, true},
+	audio.ErrUnknownBackend:   {CodeUnknownOutput, false},
+	audio.ErrUnknownDevice:    {CodeUnknownOutput, false},
+	audio.ErrUnsupportedSound: {CodeDecodeFailed, false},
+	audio.ErrInvalidWAV:       {CodeDecodeFailed, false},
+	audio.ErrWAVFormat:        {CodeDecodeFailed, false},
+	multiroom.ErrFollowing:    {CodeFollowing, false},
+	ErrVersion:                {CodeUnsupportedVersion, false},
+	ErrRateLimited:            {CodeRateLimited, true},
+	ErrUnknownTopic:           {CodeUnknownTopic, false},
+}
+
+// Constructs an error payload for an error caused by an event topic,
+// errors without a code are internal errors
+func NewErrorPayload(topic string, err error) *ErrorPayload {
+	payload := &ErrorPayload{
+		Code:  CodeInternal,
+		Error: err.Error(),
+		Topic: topic,
+	}
+	if perr, ok := err.(*player.PlayError); ok {
+		payload.PlaylistID = perr.PlaylistID
+		err = perr.Reason
+	}
+	if _, ok := err.(payloadError); ok {
+		payload.Code = CodeInvalidPayload
+	} else if os.IsNotExist(err) {
+		payload.Code = CodeFileNotFound
+	} else if c, ok := errorCodes[err]; ok {
+		payload.Code, payload.Retryable = c.code, c.retryable
+	}
+	return payload
+}
+
+// An event payload which could not be decoded
+type payloadError struct {
+	err error
+}
+
+func (e payloadError) Error() string {
+	return "invalid payload: " + e.err.Error()
+}
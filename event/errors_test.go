@@ -0,0 +1,43 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"player/audio"
+	"player/player"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewErrorPayload(t *testing.T) {
+	tt := []struct {
+		tname      string
+		err        error
+		code       string
+		playlistID string
+		retryable  bool
+	}{
+		{"playing", player.ErrPlaying, CodeAlreadyPlaying, "", true},
+		{"not paused", ErrResuming, CodeInvalidState, "", false},
+		{"stream", &player.PlayError{PlaylistID: "abc", Reason: player.ErrStreamFailed, Err: errors.New("eof")}, CodeProviderStreamFailed, "abc", true},
+		{"decode", &player.PlayError{PlaylistID: "abc", Reason: player.ErrDecodeFailed, Err: errors.New("bad frame")}, CodeDecodeFailed, "abc", false},
+		{"device", audio.ErrUnknownDevice, CodeUnknownOutput, "", false},
+		{"payload", payloadError{&json.SyntaxError{}}, CodeInvalidPayload, "", false},
+		{"file", &os.PathError{Op: "open", Path: "x.wav", Err: os.ErrNotExist}, CodeFileNotFound, "", false},
+		{"version", ErrVersion, CodeUnsupportedVersion, "", false},
+		{"unknown", errors.New("boom"), CodeInternal, "", false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			payload := NewErrorPayload(PlayEvent, tc.err)
+			assert.Equal(t, tc.code, payload.Code)
+			assert.Equal(t, tc.err.Error(), payload.Error)
+			assert.Equal(t, PlayEvent, payload.Topic)
+			assert.Equal(t, tc.playlistID, payload.PlaylistID)
+			assert.Equal(t, tc.retryable, payload.Retryable)
+		})
+	}
+}
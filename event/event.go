@@ -9,6 +9,8 @@ const (
 	PlayerReadyEvent   string = "player:ready"
 	PlayerOfflineEvent string = "player:offline"
 	PlayEvent          string = "player:play"
+	LoadingEvent       string = "player:loading"
+	BufferingEvent     string = "player:buffering"
 	PlayingEvent       string = "player:playing"
 	StopEvent          string = "player:stop"
 	StoppedEvent       string = "player:stopped"
@@ -16,7 +18,23 @@ const (
 	PausedEvent        string = "player:paused"
 	ResumeEvent        string = "player:resume"
 	ResumedEvent       string = "player:resumed"
+	FinishedEvent      string = "player:finished"
+	FailedEvent        string = "player:failed"
 	ErrorEvent         string = "player:error"
+	DeadAirEvent       string = "player:deadair"
+	AnnounceEvent      string = "player:announce"
+	OutputEvent        string = "player:output"
+	OutputChangedEvent string = "player:output:changed"
+	OutputErrorEvent   string = "player:output:error"
+	SubscribeEvent     string = "hub:subscribe"
+	UnsubscribeEvent   string = "hub:unsubscribe"
+	SubscriptionsEvent string = "hub:subscriptions"
+	ReplayEvent        string = "hub:replay"
+	ReplayedEvent      string = "hub:replayed"
+	StatusEvent        string = "hub:status"
+	StatusReportEvent  string = "hub:status:report"
+	TopicsEvent        string = "hub:topics"
+	TopicsListEvent    string = "hub:topics:list"
 )
 
 type Reader interface {
@@ -38,6 +56,10 @@ type ReadWriteCloser interface {
 }
 
 type Event struct {
+	V       int             `json:"v,omitempty"`       // Envelope version, see Version
+	Seq     uint64          `json:"seq,omitempty"`     // Journal sequence number of broadcast events
+	ID      string          `json:"id,omitempty"`      // Optional client id, echoed as replyTo
+	ReplyTo string          `json:"replyTo,omitempty"` // ID of the event this event responds to
 	Topic   string          `json:"topic"`
 	Created time.Time       `json:"created"`
 	Payload json.RawMessage `json:"payload,omitempty"`
@@ -50,6 +72,67 @@ type PlayPayload struct {
 	UserID          string `json:"userID"`          // The user who queued the track
 }
 
+type AnnouncePayload struct {
+	ProviderName    string `json:"providerID,omitempty"`      // The provider name (googlemusic, soundcloud)
+	ProviderTrackID string `json:"providerTrackID,omitempty"` // The provider track id from the provider
+	File            string `json:"file,omitempty"`            // Path to a local .wav or .mp3 file
+}
+
+type PlayingPayload struct {
+	PlaylistID string `json:"playlistID"`           // The Playlist ID of the playing track
+	UserID     string `json:"userID,omitempty"`     // The user who queued the track
+	Title      string `json:"title,omitempty"`      // Track title read from the stream tags
+	Artist     string `json:"artist,omitempty"`     // Track artist read from the stream tags
+	Album      string `json:"album,omitempty"`      // Track album read from the stream tags
+	Track      int    `json:"track,omitempty"`      // Track number read from the stream tags
+	ArtworkURL string `json:"artworkURL,omitempty"` // URL of the embedded artwork, if any
+}
+
+type LifecyclePayload struct {
+	PlaylistID string `json:"playlistID"`       // The Playlist ID of the track
+	UserID     string `json:"userID,omitempty"` // The user who queued the track
+	Reason     string `json:"reason,omitempty"` // Why the track finished or stopped, or the error code it failed with
+	Played     int64  `json:"played,omitempty"` // Milliseconds of the track played, finished, stopped and failed only
+}
+
+type OutputPayload struct {
+	Backend string `json:"backend,omitempty"` // Backend name, empty for the current backend
+	Device  string `json:"device,omitempty"`  // Device name or index, empty for the default device
+}
+
+type OutputErrorPayload struct {
+	Backend string `json:"backend"`          // Backend of the failed output
+	Device  string `json:"device,omitempty"` // Device of the failed output, empty for the default
+	Error   string `json:"error"`            // Why the output failed
+	Retry   string `json:"retry"`            // Delay before the next attempt to reopen, e.g: 2s
+}
+
 type ErrorPayload struct {
-	Error string `json:"error"`
+	Code       string `json:"code"`                 // Stable error code, e.g: ALREADY_PLAYING
+	Error      string `json:"error"`                // Human readable error message
+	Topic      string `json:"topic,omitempty"`      // Topic of the event which failed
+	PlaylistID string `json:"playlistID,omitempty"` // Playlist ID of the track which failed, if any
+	Retryable  bool   `json:"retryable"`            // True if the request may succeed if retried
+}
+
+type SubscribePayload struct {
+	Topics []string `json:"topics"` // Topic patterns, e.g: player:*
+}
+
+type TopicsPayload struct {
+	Topics []string `json:"topics"` // Handled topics
+}
+
+type ReplayPayload struct {
+	Since uint64 `json:"since"` // Sequence number of the last event received
+}
+
+type ReplayedPayload struct {
+	Since    uint64 `json:"since"`    // Sequence number replayed from
+	Count    int    `json:"count"`    // Number of events replayed
+	Complete bool   `json:"complete"` // False if events after since were dropped from the journal
+}
+
+type DeadAirPayload struct {
+	PlaylistID string `json:"playlistID"` // The Playlist ID of the silent track
 }
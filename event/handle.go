@@ -0,0 +1,62 @@
+package event
+
+import (
+	"errors"
+	"sort"
+
+	"player/logger"
+)
+
+// Unknown topic error
+var ErrUnknownTopic = errors.New("unknown topic, no handler registered")
+
+// Registers the handler of an event topic, replacing any handler the
+// topic already has
+func Handle(topic string, h HandlerFunc) { hub.Handle(topic, h) }
+func (hub *Hub) Handle(topic string, h HandlerFunc) {
+	hub.handlersLock.Lock()
+	hub.handlers[topic] = h
+	hub.handlersLock.Unlock()
+}
+
+// Returns the handled topics in order
+func Topics() []string { return hub.Topics() }
+func (hub *Hub) Topics() []string {
+	hub.handlersLock.Lock()
+	defer hub.handlersLock.Unlock()
+	topics := make([]string, 0, len(hub.handlers))
+	for topic := range hub.handlers {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// Returns the handler of a topic, nil if the topic is not handled
+func (hub *Hub) handler(topic string) HandlerFunc {
+	hub.handlersLock.Lock()
+	defer hub.handlersLock.Unlock()
+	return hub.handlers[topic]
+}
+
+// The topics event replies with the handled topics
+func (hub *Hub) listTopics(ce ClientEvent) error {
+	logger.Debug("handle topics event")
+	return hub.replyEvent(ce, TopicsListEvent, &TopicsPayload{Topics: hub.Topics()})
+}
+
+// Registers the handlers of the topics built into the hub
+func (hub *Hub) handleBuiltin() {
+	hub.Handle(PauseEvent, hub.pausePlayer)
+	hub.Handle(ResumeEvent, hub.resumePlayer)
+	hub.Handle(PlayEvent, hub.playTrack)
+	hub.Handle(StopEvent, hub.stopTrack)
+	hub.Handle(AnnounceEvent, hub.announce)
+	hub.Handle(OutputEvent, hub.output)
+	hub.Handle(ErrorEvent, hub.eventError)
+	hub.Handle(SubscribeEvent, hub.subscribe)
+	hub.Handle(UnsubscribeEvent, hub.unsubscribe)
+	hub.Handle(ReplayEvent, hub.replay)
+	hub.Handle(StatusEvent, hub.status)
+	hub.Handle(TopicsEvent, hub.listTopics)
+}
@@ -0,0 +1,52 @@
+package event
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHubHandle(t *testing.T) {
+	hub := New()
+	handled := false
+	hub.Handle("dsp:gain", func(ce ClientEvent) error {
+		handled = true
+		return nil
+	})
+	client := &testClient{}
+	assert.NoError(t, hub.handleEvent(ClientEvent{client, Event{Topic: "dsp:gain"}}))
+	assert.True(t, handled)
+	assert.Len(t, client.written, 0)
+	assert.Contains(t, hub.Topics(), "dsp:gain")
+	assert.Contains(t, hub.Topics(), PlayEvent)
+}
+
+func TestHubUnknownTopic(t *testing.T) {
+	hub := New()
+	client := &testClient{}
+	assert.NoError(t, hub.handleEvent(ClientEvent{client, Event{ID: "1", Topic: "foo:bar"}}))
+	assert.Len(t, client.written, 1)
+	e := Event{}
+	assert.NoError(t, json.Unmarshal(client.written[0], &e))
+	assert.Equal(t, ErrorEvent, e.Topic)
+	assert.Equal(t, "1", e.ReplyTo)
+	payload := ErrorPayload{}
+	assert.NoError(t, json.Unmarshal(e.Payload, &payload))
+	assert.Equal(t, CodeUnknownTopic, payload.Code)
+	assert.Equal(t, "foo:bar", payload.Topic)
+}
+
+func TestHubTopics(t *testing.T) {
+	hub := New()
+	client := &testClient{}
+	assert.NoError(t, hub.handleEvent(ClientEvent{client, Event{Topic: TopicsEvent}}))
+	assert.Len(t, client.written, 1)
+	e := Event{}
+	assert.NoError(t, json.Unmarshal(client.written[0], &e))
+	assert.Equal(t, TopicsListEvent, e.Topic)
+	payload := TopicsPayload{}
+	assert.NoError(t, json.Unmarshal(e.Payload, &payload))
+	assert.Equal(t, hub.Topics(), payload.Topics)
+	assert.Contains(t, payload.Topics, TopicsEvent)
+}
@@ -8,8 +8,12 @@ import (
 	"sync"
 	"time"
 
+	"player/artwork"
+	"player/audio"
 	"player/logger"
+	"player/multiroom"
 	"player/player"
+	"player/tags"
 )
 
 // Global event hub
@@ -21,8 +25,39 @@ var (
 	ErrPausng   = errors.New("cannot pause, not playing or already paused")
 	ErrResuming = errors.New("cannot resume, not playing or not paused")
 	ErrStopping = errors.New("cannot stop, not playing")
+	ErrVersion  = errors.New("unsupported event version")
 )
 
+// Topics broadcast as the outcome of a client event, the broadcast
+// replies to the event which caused it
+var outcomes = map[string]string{
+	PlayEvent:   PlayingEvent,
+	PauseEvent:  PausedEvent,
+	ResumeEvent: ResumedEvent,
+	StopEvent:   StoppedEvent,
+}
+
+// Topics of track lifecycle states
+var lifecycleTopics = map[player.State]string{
+	player.Loading:   LoadingEvent,
+	player.Buffering: BufferingEvent,
+	player.Playing:   PlayingEvent,
+	player.Paused:    PausedEvent,
+	player.Resumed:   ResumedEvent,
+	player.Finished:  FinishedEvent,
+	player.Stopped:   StoppedEvent,
+	player.Failed:    FailedEvent,
+}
+
+// How long to wait for track tags before sending the playing event
+const tagsWait = time.Second * 2
+
+// Returns when audio written now will be heard, player timing events
+// are stamped with it so clients line up with what is audible
+func heard() time.Time {
+	return time.Now().Add(audio.Latency()).UTC()
+}
+
 // Package initialiser
 func init() {
 	hub = New()
@@ -63,13 +98,27 @@ type ClientEvent struct {
 // Event Hub
 type Hub struct {
 	// Exported Fields
+	Config Configurer
 	// Unexported Fields
-	decoder     Decoder // JSON Decoder
-	clientsLock *sync.Mutex
-	clients     Clients
-	eventsC     chan ClientEvent
-	closeWg     *sync.WaitGroup
-	closeC      chan bool
+	decoder        Decoder // JSON Decoder
+	clientsLock    *sync.Mutex
+	clients        Clients
+	subscriptions  map[string][]string // Client id to the topic patterns broadcast to it
+	joined         map[string]uint64   // Client id to the journal sequence when it was added
+	writers        map[string]*writer  // Client id to the writer buffering its events
+	journal        *Journal
+	statusLock     *sync.Mutex
+	statuses       map[string]StatusFunc
+	middlewareLock *sync.Mutex
+	inbound        []Middleware
+	handlersLock   *sync.Mutex
+	handlers       map[string]HandlerFunc // Topic to its handler
+	outbound       []OutboundMiddleware
+	causesLock     *sync.Mutex
+	causes         map[string]string // Outcome topic to the id of the event causing it
+	eventsC        chan ClientEvent
+	closeWg        *sync.WaitGroup
+	closeC         chan bool
 }
 
 // Add clients to the hub
@@ -78,6 +127,9 @@ func (hub *Hub) Add(client Client) {
 	logger.Debug("add hub client")
 	hub.clientsLock.Lock()
 	hub.clients.Add(client)
+	hub.joined[client.ID()] = hub.journal.Seq()
+	hub.addWriter(client)
+	hub.closeWg.Add(1)
 	go hub.read(client)
 	hub.clientsLock.Unlock()
 	logger.Debug("added hub client")
@@ -88,8 +140,12 @@ func Del(client Client) { hub.Del(client) }
 func (hub *Hub) Del(client Client) {
 	logger.Debug("delete hub client")
 	hub.clientsLock.Lock()
-	hub.clients.Del(client)
+	w := hub.remove(client)
 	hub.clientsLock.Unlock()
+	// Events already buffered are written before the client goes
+	if w != nil {
+		<-w.doneC
+	}
 	logger.Debug("deleted hub client")
 }
 
@@ -100,33 +156,28 @@ func (hub *Hub) ProcessEvents() {
 	defer logger.Debug("exit process events")
 	hub.closeWg.Add(1)
 	defer hub.closeWg.Done()
+	// Lifecycle changes are handled in order on their own
+	hub.closeWg.Add(1)
+	go hub.lifecycle()
 	for {
 		select {
 		case <-hub.closeC:
 			return
-		case <-player.Playing(): // The player is playing
+		case track := <-player.DeadAir(): // The player has gone silent
+			hub.closeWg.Add(1)
 			go func() {
-				hub.closeWg.Add(1)
-				if err := hub.playingTrack(); err != nil {
-					logger.WithError(err).Error("error handling playing event")
+				defer hub.closeWg.Done()
+				if err := hub.deadAir(track); err != nil {
+					logger.WithError(err).Error("error handling dead air event")
 				}
-				hub.closeWg.Done()
-			}()
-		case <-player.Stopped(): // The player has stopped
-			go func() {
-				hub.closeWg.Add(1)
-				if err := hub.stoppedPlayer(); err != nil {
-					logger.WithError(err).Error("error handling stopped event")
-				}
-				hub.closeWg.Done()
 			}()
-		case <-player.Paused(): // The player has paused
+		case status := <-audio.Status(): // The audio output failed or recovered
+			hub.closeWg.Add(1)
 			go func() {
-				hub.closeWg.Add(1)
-				if err := hub.pausedPlayer(); err != nil {
-					logger.WithError(err).Error("error handling paused event")
+				defer hub.closeWg.Done()
+				if err := hub.outputStatus(status); err != nil {
+					logger.WithError(err).Error("error handling output status")
 				}
-				hub.closeWg.Done()
 			}()
 		case event := <-hub.eventsC: // Client events
 			go func() {
@@ -140,20 +191,34 @@ func (hub *Hub) ProcessEvents() {
 	}
 }
 
-// Broadcast an event to all connected clients
+// Broadcast an event to all connected clients subscribed to its topic
 func Broadcast(event Event) error { return hub.Broadcast(event) }
 func (hub *Hub) Broadcast(event Event) error {
+	return hub.emit(event, nil)
+}
+
+// Journals an event and writes it to all subscribed clients
+func (hub *Hub) broadcast(event Event) error {
+	event.V = Version
+	// Journaled under the clients lock so clients receive events in
+	// sequence and replays do not overlap live events
+	hub.clientsLock.Lock()
+	defer hub.clientsLock.Unlock()
+	if err := hub.journal.Append(&event); err != nil {
+		logger.WithError(err).Error("error writing event journal")
+	}
 	body, err := json.Marshal(&event)
 	if err != nil {
 		return err
 	}
-	hub.clientsLock.Lock()
-	for _, client := range hub.clients {
-		if _, err := client.Write(body); err != nil {
+	for id, client := range hub.clients {
+		if !hub.subscribed(id, event.Topic) {
+			continue
+		}
+		if err := hub.enqueue(client, body); err != nil {
 			logger.WithError(err).Error("error writting to client")
 		}
 	}
-	hub.clientsLock.Unlock()
 	return nil
 }
 
@@ -191,7 +256,7 @@ func (hub *Hub) Close() error {
 	defer logger.Debug("closed event hub")
 	close(hub.closeC)
 	hub.closeWg.Wait() // Wait for coroutines to exit
-	return nil
+	return hub.journal.Close()
 }
 
 // Decode raw byte data into interface, defaults to json decoder
@@ -208,8 +273,8 @@ func (hub *Hub) decode(b []byte, v interface{}) error {
 func (hub *Hub) read(client Client) error {
 	logger.Debug("start client read loop")
 	defer logger.Debug("exit client read loop")
-	hub.closeWg.Add(1)
 	defer hub.closeWg.Done()
+	first := true
 	for { // Read from the client
 		raw, err := client.Read() // Blocking
 		if err != nil {
@@ -225,6 +290,13 @@ func (hub *Hub) read(client Client) error {
 			logger.WithError(err).Error("error decoding event json")
 			continue
 		}
+		// Only clients replaying missed events first hold live events
+		if first && event.Topic != ReplayEvent {
+			hub.clientsLock.Lock()
+			hub.unhold(hub.writers[client.ID()])
+			hub.clientsLock.Unlock()
+		}
+		first = false
 		// Add the event to the event channel wtith the origional client attached
 		hub.eventsC <- ClientEvent{client, event}
 	}
@@ -234,84 +306,41 @@ func (hub *Hub) read(client Client) error {
 // actions to all attachec clients, other only reply to the client
 // that origionated the event, this is the case for error responses
 // so errors can be surfaced back to the clients
-func (hub *Hub) handleEvent(ce ClientEvent) error {
+func (hub *Hub) dispatch(ce ClientEvent) error {
 	logger.Debug("handle event")
-	switch ce.Event.Topic {
-	case PauseEvent:
-		return hub.pausePlayer(ce)
-	case ResumeEvent:
-		return hub.resumePlayer(ce)
-	case PlayEvent:
-		return hub.playTrack(ce)
-	case StopEvent:
-		return hub.stopTrack(ce)
-	case ErrorEvent:
-		return hub.eventError(ce)
+	hub.expect(ce.Event)
+	if err := Validate(ce.Event); err != nil {
+		return hub.replyError(ce, err)
 	}
-	return nil
+	h := hub.handler(ce.Event.Topic)
+	if h == nil {
+		return hub.replyError(ce, ErrUnknownTopic)
+	}
+	return h(ce)
 }
 
 // Pause event pauses the player, if the player is playing and not paused
-// the player.Pause method will return true if the player was paused and
+// the multiroom.Pause method will return true if the player was paused and
 // false if the player was not paused
 func (hub *Hub) pausePlayer(ce ClientEvent) error {
 	logger.Debug("handle pause event")
-	if !player.Pause() {
+	if !multiroom.Pause() {
 		// If the player is not playing or is already paused
 		// error back to the origional client
-		payload, err := json.Marshal(&ErrorPayload{
-			Error: ErrPausng.Error(),
-		})
-		if err != nil {
-			return err
-		}
-		hub.eventsC <- ClientEvent{
-			Client: ce.Client,
-			Event: Event{
-				Topic:   ErrorEvent,
-				Created: time.Now().UTC(),
-				Payload: json.RawMessage(payload),
-			},
-		}
-	}
-	return nil
-}
-
-// Triggered by the player pause event
-func (hub *Hub) pausedPlayer() error {
-	logger.Debug("handle paused event")
-	event := Event{
-		Topic:   PausedEvent,
-		Created: time.Now().UTC(),
-	}
-	if err := hub.Broadcast(event); err != nil {
-		return err
+		return hub.replyError(ce, ErrPausng)
 	}
 	return nil
 }
 
 // Resume event resumes the player, if the player is playing and paused
-// the player.Resume method will return true if the player was resumed and
+// the multiroom.Resume method will return true if the player was resumed and
 // false if the player was not resumed
 func (hub *Hub) resumePlayer(ce ClientEvent) error {
 	logger.Debug("handle resume event")
-	if !player.Resume() {
+	if !multiroom.Resume() {
 		// If the player is not playing or is not paused
 		// error back to the origional client
-		payload, err := json.Marshal(&ErrorPayload{
-			Error: ErrResuming.Error(),
-		})
-		if err != nil {
-			return err
-		}
-		hub.eventsC <- ClientEvent{
-			Client: ce.Client,
-			Event: Event{
-				Topic:   ErrorEvent,
-				Created: time.Now().UTC(),
-				Payload: json.RawMessage(payload),
-			},
-		}
+		return hub.replyError(ce, ErrResuming)
 	}
 	return nil
 }
@@ -324,78 +353,271 @@ func (hub *Hub) playTrack(ce ClientEvent) error {
 	logger.Debug("handle play event")
 	payload := &PlayPayload{}
 	if err := json.Unmarshal(ce.Event.Payload, payload); err != nil {
-		return err
+		return hub.replyError(ce, payloadError{err})
 	}
-	err := player.Play(player.LoadTrackConfig{
+	err := multiroom.Play(player.LoadTrackConfig{
 		ProviderName:    payload.ProviderName,
 		ProviderTrackID: payload.ProviderTrackID,
 		PlaylistID:      payload.PlaylistID,
+		UserID:          payload.UserID,
 	})
 	if err != nil {
-		payload, err := json.Marshal(&ErrorPayload{
-			Error: err.Error(),
-		})
-		if err != nil {
-			return err
+		e := NewErrorPayload(ce.Event.Topic, err)
+		if e.PlaylistID == "" {
+			e.PlaylistID = payload.PlaylistID
 		}
-		body, err := json.Marshal(&Event{
-			Topic:   ErrorEvent,
-			Created: time.Now().UTC(),
-			Payload: json.RawMessage(payload),
-		})
-		if err != nil {
-			return err
+		return hub.reply(ce, e)
+	}
+	return nil
+}
+
+// The announce event plays a provider track or local sound file over
+// the top of the current track, errors are written back to the client
+func (hub *Hub) announce(ce ClientEvent) error {
+	logger.Debug("handle announce event")
+	payload := &AnnouncePayload{}
+	if err := json.Unmarshal(ce.Event.Payload, payload); err != nil {
+		return hub.replyError(ce, payloadError{err})
+	}
+	err := player.Announce(player.AnnounceConfig{
+		ProviderName:    payload.ProviderName,
+		ProviderTrackID: payload.ProviderTrackID,
+		File:            payload.File,
+	})
+	if err != nil {
+		return hub.replyError(ce, err)
+	}
+	return nil
+}
+
+// The output event switches the audio output backend and device,
+// broadcasting the output changed event on success
+func (hub *Hub) output(ce ClientEvent) error {
+	logger.Debug("handle output event")
+	payload := &OutputPayload{}
+	if err := json.Unmarshal(ce.Event.Payload, payload); err != nil {
+		return hub.replyError(ce, payloadError{err})
+	}
+	if err := audio.SetOutput(payload.Backend, payload.Device); err != nil {
+		return hub.replyError(ce, err)
+	}
+	payload.Backend, payload.Device = audio.Current()
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return hub.Broadcast(Event{
+		ReplyTo: ce.Event.ID,
+		Topic:   OutputChangedEvent,
+		Created: time.Now().UTC(),
+		Payload: json.RawMessage(b),
+	})
+}
+
+// Triggered by the audio output failing or being reopened, broadcasts
+// the output error event on failure or the output changed event once
+// the output has been reopened
+func (hub *Hub) outputStatus(status audio.OutputStatus) error {
+	logger.Debug("handle output status")
+	event := Event{
+		Topic:   OutputChangedEvent,
+		Created: time.Now().UTC(),
+	}
+	var payload interface{} = &OutputPayload{
+		Backend: status.Backend,
+		Device:  status.Device,
+	}
+	if status.Err != nil {
+		event.Topic = OutputErrorEvent
+		payload = &OutputErrorPayload{
+			Backend: status.Backend,
+			Device:  status.Device,
+			Error:   status.Err.Error(),
+			Retry:   status.Retry.String(),
+		}
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	event.Payload = json.RawMessage(b)
+	return hub.Broadcast(event)
+}
+
+// Broadcasts track lifecycle changes in the order they happened until
+// the hub is closed
+func (hub *Hub) lifecycle() {
+	logger.Debug("start lifecycle events")
+	defer logger.Debug("exit lifecycle events")
+	defer hub.closeWg.Done()
+	for {
+		select {
+		case <-hub.closeC:
+			return
+		case c := <-player.Lifecycle():
+			if err := hub.trackChanged(c); err != nil {
+				logger.WithError(err).Error("error handling lifecycle event")
+			}
 		}
-		if _, err := ce.Client.Write(body); err != nil {
-			return err
+	}
+}
+
+// Triggered by a track lifecycle change, broadcasts the lifecycle event
+func (hub *Hub) trackChanged(c player.Change) error {
+	logger.WithField("state", c.State).Debug("handle lifecycle change")
+	if c.State == player.Playing {
+		return hub.playingTrack(c)
+	}
+	topic := lifecycleTopics[c.State]
+	replyTo := hub.cause(topic, c.PlaylistID)
+	if c.Terminal() {
+		// A play which ended before playing no longer expects an outcome,
+		// if it failed the failure is the reply
+		if id := hub.cause(PlayingEvent, c.PlaylistID); c.State == player.Failed {
+			replyTo = id
 		}
 	}
-	return nil
+	if c.State == player.Failed {
+		hub.playErrors(c.PlaylistID, replyTo)
+	}
+	payload := LifecyclePayload{
+		PlaylistID: c.PlaylistID,
+		UserID:     c.UserID,
+	}
+	created := heard()
+	switch c.State {
+	case player.Finished, player.Stopped:
+		payload.Reason = c.Reason
+		payload.Played = int64(c.Played / time.Millisecond)
+	case player.Failed:
+		payload.Reason = NewErrorPayload(PlayEvent, c.Err).Code
+		payload.Played = int64(c.Played / time.Millisecond)
+		created = time.Now().UTC()
+	case player.Loading:
+		created = time.Now().UTC()
+	}
+	if c.Terminal() {
+		audio.SetMetadata("")
+	}
+	b, err := json.Marshal(&payload)
+	if err != nil {
+		return err
+	}
+	return hub.Broadcast(Event{
+		ReplyTo: replyTo,
+		Topic:   topic,
+		Created: created,
+		Payload: json.RawMessage(b),
+	})
 }
 
-// Triggered by the player playing event
-func (hub *Hub) playingTrack() error {
+// Triggered by the player playing, including after buffering mid track,
+// broadcasts the playing event with the track tags
+func (hub *Hub) playingTrack(c player.Change) error {
 	logger.Debug("handle playing event")
 	event := Event{
+		ReplyTo: hub.cause(PlayingEvent, c.PlaylistID),
 		Topic:   PlayingEvent,
-		Created: time.Now().UTC(),
+		Created: heard(),
 	}
+	payload := PlayingPayload{
+		PlaylistID: c.PlaylistID,
+		UserID:     c.UserID,
+	}
+	if track := player.Current(); track != nil && track.PlaylistID == c.PlaylistID {
+		if t := track.Tags(tagsWait); t != nil {
+			payload.Title = t.Title
+			payload.Artist = t.Artist
+			payload.Album = t.Album
+			payload.Track = t.Track
+			audio.SetMetadata(nowPlaying(t))
+			if t.Picture != nil {
+				artwork.Add(track.PlaylistID, t.Picture)
+				payload.ArtworkURL = artwork.URL(track.PlaylistID)
+			}
+		}
+	}
+	b, err := json.Marshal(&payload)
+	if err != nil {
+		return err
+	}
+	event.Payload = json.RawMessage(b)
 	if err := hub.Broadcast(event); err != nil {
 		return err
 	}
 	return nil
 }
 
+// Returns the now playing title for stream metadata, e.g: Artist - Title
+func nowPlaying(t *tags.Tags) string {
+	if t.Artist == "" {
+		return t.Title
+	}
+	if t.Title == "" {
+		return t.Artist
+	}
+	return t.Artist + " - " + t.Title
+}
+
 // The stop event will trigger the player to stop playing a track
 // The player Stop method will retrun true if the stop event has
 // been triggered on the player, else it will return false
 func (hub *Hub) stopTrack(ce ClientEvent) error {
 	logger.Debug("handle stop event")
-	if !player.Stop() {
-		payload, err := json.Marshal(&ErrorPayload{
-			Error: ErrStopping.Error(),
-		})
-		if err != nil {
-			return err
-		}
-		hub.eventsC <- ClientEvent{
-			Client: ce.Client,
-			Event: Event{
-				Topic:   ErrorEvent,
-				Created: time.Now().UTC(),
-				Payload: json.RawMessage(payload),
-			},
-		}
+	if !multiroom.Stop() {
+		return hub.replyError(ce, ErrStopping)
 	}
 	return nil
 }
 
-// Triggered by a player stopped event
-func (hub *Hub) stoppedPlayer() error {
-	logger.Debug("handle player stopped event")
+// Broadcasts the errors which ended playback before the failed event of
+// a track, the player sends them before the track fails so they are
+// already waiting, the error of the failed track replies to its play
+func (hub *Hub) playErrors(playlistID, replyTo string) {
+	for {
+		select {
+		case failure := <-player.Errors():
+			id := ""
+			if pe, ok := failure.(*player.PlayError); ok && pe.PlaylistID == playlistID {
+				id = replyTo
+			}
+			if err := hub.playError(failure, id); err != nil {
+				logger.WithError(err).Error("error handling playback error")
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Broadcasts an error which ended playback of a track
+func (hub *Hub) playError(failure error, replyTo string) error {
+	logger.Debug("handle playback error")
+	payload, err := json.Marshal(NewErrorPayload(PlayEvent, failure))
+	if err != nil {
+		return err
+	}
+	return hub.Broadcast(Event{
+		ReplyTo: replyTo,
+		Topic:   ErrorEvent,
+		Created: time.Now().UTC(),
+		Payload: json.RawMessage(payload),
+	})
+}
+
+// Triggered by the player detecting dead air in the playing track
+func (hub *Hub) deadAir(track *player.Track) error {
+	logger.Debug("handle player dead air event")
+	payload, err := json.Marshal(&DeadAirPayload{
+		PlaylistID: track.PlaylistID,
+	})
+	if err != nil {
+		return err
+	}
 	event := Event{
-		Topic:   StoppedEvent,
+		Topic:   DeadAirEvent,
 		Created: time.Now().UTC(),
+		Payload: json.RawMessage(payload),
 	}
 	if err := hub.Broadcast(event); err != nil {
 		return err
@@ -403,26 +625,114 @@ func (hub *Hub) stoppedPlayer() error {
 	return nil
 }
 
-// Write an error event to the client
-func (hub *Hub) eventError(ce ClientEvent) error {
-	logger.Debug("handle error event")
-	body, err := json.Marshal(&ce.Event)
+// Returns the key of an expected outcome, plays are expected per track so
+// the outcome of an earlier track is never attributed to a later play
+func outcomeKey(topic, playlistID string) string {
+	if topic != PlayingEvent {
+		return topic
+	}
+	return topic + ":" + playlistID
+}
+
+// Records the id of an event so the broadcast of its outcome replies to
+// it, events without an id or an outcome are ignored
+func (hub *Hub) expect(event Event) {
+	topic, ok := outcomes[event.Topic]
+	if !ok || event.ID == "" {
+		return
+	}
+	payload := &PlayPayload{}
+	if event.Topic == PlayEvent {
+		json.Unmarshal(event.Payload, payload) // Invalid payloads are replied to
+	}
+	hub.causesLock.Lock()
+	hub.causes[outcomeKey(topic, payload.PlaylistID)] = event.ID
+	hub.causesLock.Unlock()
+}
+
+// Returns the id of the event which caused an outcome of a track, if
+// any, and forgets it so later outcomes are not attributed to it
+func (hub *Hub) cause(topic, playlistID string) string {
+	key := outcomeKey(topic, playlistID)
+	hub.causesLock.Lock()
+	defer hub.causesLock.Unlock()
+	id := hub.causes[key]
+	delete(hub.causes, key)
+	return id
+}
+
+// Writes an error event in reply to a client event
+func (hub *Hub) replyError(ce ClientEvent, failure error) error {
+	return hub.reply(ce, NewErrorPayload(ce.Event.Topic, failure))
+}
+
+// Writes an error payload in reply to a client event, the event no
+// longer expects an outcome
+func (hub *Hub) reply(ce ClientEvent, e *ErrorPayload) error {
+	if _, ok := outcomes[ce.Event.Topic]; ok && ce.Event.ID != "" {
+		hub.causesLock.Lock()
+		for key, id := range hub.causes {
+			if id == ce.Event.ID {
+				delete(hub.causes, key)
+			}
+		}
+		hub.causesLock.Unlock()
+	}
+	payload, err := json.Marshal(e)
 	if err != nil {
 		return err
 	}
-	if _, err := ce.Client.Write(body); err != nil {
+	return hub.emit(Event{
+		V:       Version,
+		ReplyTo: ce.Event.ID,
+		Topic:   ErrorEvent,
+		Created: time.Now().UTC(),
+		Payload: json.RawMessage(payload),
+	}, ce.Client)
+}
+
+// Writes an event in reply to a client event
+func (hub *Hub) replyEvent(ce ClientEvent, topic string, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
 		return err
 	}
-	return nil
+	return hub.emit(Event{
+		V:       Version,
+		ReplyTo: ce.Event.ID,
+		Topic:   topic,
+		Created: time.Now().UTC(),
+		Payload: json.RawMessage(payload),
+	}, ce.Client)
+}
+
+// Write an error event to the client
+func (hub *Hub) eventError(ce ClientEvent) error {
+	logger.Debug("handle error event")
+	return hub.emit(ce.Event, ce.Client)
 }
 
 // Hub Constructor
 func New() *Hub {
-	return &Hub{
-		clientsLock: &sync.Mutex{},
-		clients:     make(Clients),
-		eventsC:     make(chan ClientEvent),
-		closeWg:     &sync.WaitGroup{},
-		closeC:      make(chan bool),
+	hub := &Hub{
+		Config:         NewConfig(),
+		clientsLock:    &sync.Mutex{},
+		clients:        make(Clients),
+		subscriptions:  make(map[string][]string),
+		joined:         make(map[string]uint64),
+		writers:        make(map[string]*writer),
+		journal:        &Journal{size: journalSize},
+		statusLock:     &sync.Mutex{},
+		statuses:       make(map[string]StatusFunc),
+		middlewareLock: &sync.Mutex{},
+		handlersLock:   &sync.Mutex{},
+		handlers:       make(map[string]HandlerFunc),
+		causesLock:     &sync.Mutex{},
+		causes:         make(map[string]string),
+		eventsC:        make(chan ClientEvent),
+		closeWg:        &sync.WaitGroup{},
+		closeC:         make(chan bool),
 	}
+	hub.handleBuiltin()
+	return hub
 }
@@ -0,0 +1,360 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"player/player"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Records events written to it
+type testClient struct {
+	id      string
+	written [][]byte
+}
+
+func (c *testClient) ID() string            { return c.id }
+func (c *testClient) Read() ([]byte, error) { return nil, io.EOF }
+func (c *testClient) Close() error          { return nil }
+func (c *testClient) Write(b []byte) (int, error) {
+	c.written = append(c.written, b)
+	return len(b), nil
+}
+
+func TestHubCause(t *testing.T) {
+	tt := []struct {
+		tname      string
+		event      Event
+		outcome    string
+		playlistID string
+		replyTo    string
+	}{
+		{"play", Event{ID: "1", Topic: PlayEvent, Payload: json.RawMessage(`{"playlistID":"a"}`)}, PlayingEvent, "a", "1"},
+		{"play other track", Event{ID: "1", Topic: PlayEvent, Payload: json.RawMessage(`{"playlistID":"a"}`)}, PlayingEvent, "b", ""},
+		{"stop", Event{ID: "2", Topic: StopEvent}, StoppedEvent, "a", "2"},
+		{"no id", Event{Topic: PauseEvent}, PausedEvent, "", ""},
+		{"no outcome", Event{ID: "3", Topic: AnnounceEvent}, PlayingEvent, "", ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			hub := New()
+			hub.expect(tc.event)
+			assert.Equal(t, tc.replyTo, hub.cause(tc.outcome, tc.playlistID))
+			// Only the first outcome replies
+			assert.Equal(t, "", hub.cause(tc.outcome, tc.playlistID))
+		})
+	}
+}
+
+func TestHubReplyError(t *testing.T) {
+	hub := New()
+	client := &testClient{}
+	ce := ClientEvent{client, Event{ID: "1", Topic: PlayEvent}}
+	hub.expect(ce.Event)
+	assert.NoError(t, hub.replyError(ce, errors.New("boom")))
+	// The failed event no longer expects an outcome
+	assert.Equal(t, "", hub.cause(PlayingEvent, ""))
+	assert.Len(t, client.written, 1)
+	e := Event{}
+	assert.NoError(t, json.Unmarshal(client.written[0], &e))
+	assert.Equal(t, ErrorEvent, e.Topic)
+	assert.Equal(t, "1", e.ReplyTo)
+	payload := ErrorPayload{}
+	assert.NoError(t, json.Unmarshal(e.Payload, &payload))
+	assert.Equal(t, "boom", payload.Error)
+}
+
+func TestHubFailedRepliesToPlay(t *testing.T) {
+	hub := New()
+	client := &testClient{id: "1"}
+	hub.clients.Add(client)
+	hub.expect(Event{ID: "p", Topic: PlayEvent, Payload: json.RawMessage(`{"playlistID":"a"}`)})
+	assert.NoError(t, hub.trackChanged(player.Change{
+		State:      player.Failed,
+		PlaylistID: "a",
+		Err:        &player.PlayError{PlaylistID: "a", Reason: player.ErrOutputFailed, Err: errors.New("boom")},
+	}))
+	// The play no longer expects an outcome
+	assert.Equal(t, "", hub.cause(PlayingEvent, "a"))
+	if !assert.Len(t, client.written, 1) {
+		return
+	}
+	e := Event{}
+	assert.NoError(t, json.Unmarshal(client.written[0], &e))
+	assert.Equal(t, FailedEvent, e.Topic)
+	assert.Equal(t, "p", e.ReplyTo)
+}
+
+func TestHubSubscribe(t *testing.T) {
+	tt := []struct {
+		tname       string
+		subscribe   []string
+		unsubscribe []string
+		topic       string
+		received    bool
+	}{
+		{"no subscriptions", nil, nil, PlayingEvent, true},
+		{"exact", []string{PlayingEvent}, nil, PlayingEvent, true},
+		{"pattern", []string{"player:*"}, nil, StoppedEvent, true},
+		{"not matched", []string{"player:output:*"}, nil, PlayingEvent, false},
+		{"unsubscribed", []string{"player:*"}, []string{"player:*"}, PlayingEvent, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			hub := New()
+			client := &testClient{id: "1"}
+			hub.clients.Add(client)
+			if tc.subscribe != nil {
+				assert.NoError(t, hub.subscribe(subscription(client, SubscribeEvent, tc.subscribe)))
+			}
+			if tc.unsubscribe != nil {
+				assert.NoError(t, hub.unsubscribe(subscription(client, UnsubscribeEvent, tc.unsubscribe)))
+			}
+			client.written = nil
+			assert.NoError(t, hub.Broadcast(Event{Topic: tc.topic}))
+			assert.Equal(t, tc.received, len(client.written) == 1)
+		})
+	}
+}
+
+func TestHubSubscribeBadPattern(t *testing.T) {
+	hub := New()
+	client := &testClient{id: "1"}
+	assert.NoError(t, hub.subscribe(subscription(client, SubscribeEvent, []string{"player:["})))
+	assert.Len(t, client.written, 1)
+	e := Event{}
+	assert.NoError(t, json.Unmarshal(client.written[0], &e))
+	assert.Equal(t, ErrorEvent, e.Topic)
+	payload := ErrorPayload{}
+	assert.NoError(t, json.Unmarshal(e.Payload, &payload))
+	assert.Equal(t, CodeInvalidPayload, payload.Code)
+}
+
+// Returns a subscription event from a client
+func subscription(client Client, topic string, topics []string) ClientEvent {
+	payload, _ := json.Marshal(&SubscribePayload{Topics: topics})
+	return ClientEvent{client, Event{Topic: topic, Payload: payload}}
+}
+
+func TestHubReplay(t *testing.T) {
+	hub := New()
+	assert.NoError(t, hub.Broadcast(Event{Topic: PlayingEvent}))
+	assert.NoError(t, hub.Broadcast(Event{Topic: StoppedEvent}))
+	client := &testClient{id: "1"}
+	hub.clients.Add(client)
+	hub.joined[client.ID()] = hub.journal.Seq()
+	assert.NoError(t, hub.Broadcast(Event{Topic: PlayingEvent}))
+	client.written = nil
+	payload, _ := json.Marshal(&ReplayPayload{Since: 1})
+	assert.NoError(t, hub.replay(ClientEvent{client, Event{ID: "r", Topic: ReplayEvent, Payload: payload}}))
+	// Only events missed before the client was added are replayed
+	assert.Len(t, client.written, 2)
+	e := Event{}
+	assert.NoError(t, json.Unmarshal(client.written[0], &e))
+	assert.Equal(t, StoppedEvent, e.Topic)
+	assert.Equal(t, uint64(2), e.Seq)
+	assert.NoError(t, json.Unmarshal(client.written[1], &e))
+	assert.Equal(t, ReplayedEvent, e.Topic)
+	assert.Equal(t, "r", e.ReplyTo)
+	replayed := ReplayedPayload{}
+	assert.NoError(t, json.Unmarshal(e.Payload, &replayed))
+	assert.Equal(t, ReplayedPayload{Since: 1, Count: 1, Complete: true}, replayed)
+}
+
+// Sends the topics of events written to it
+type topicClient struct {
+	id      string
+	topicsC chan string
+}
+
+func (c *topicClient) ID() string            { return c.id }
+func (c *topicClient) Read() ([]byte, error) { return nil, io.EOF }
+func (c *topicClient) Close() error          { return nil }
+func (c *topicClient) Write(b []byte) (int, error) {
+	e := Event{}
+	if err := json.Unmarshal(b, &e); err != nil {
+		return 0, err
+	}
+	c.topicsC <- e.Topic
+	return len(b), nil
+}
+
+func TestHubReplayBeforeHeldEvents(t *testing.T) {
+	hub := New()
+	hub.Config = testConfig{buffer: 8, hold: time.Second * 5}
+	assert.NoError(t, hub.Broadcast(Event{Topic: StoppedEvent}))
+	client := &topicClient{"1", make(chan string, 8)}
+	hub.Add(client)
+	assert.NoError(t, hub.Broadcast(Event{Topic: PlayingEvent}))
+	payload, _ := json.Marshal(&ReplayPayload{Since: 0})
+	assert.NoError(t, hub.replay(ClientEvent{client, Event{Topic: ReplayEvent, Payload: payload}}))
+	var topics []string
+	for len(topics) < 3 {
+		select {
+		case topic := <-client.topicsC:
+			topics = append(topics, topic)
+		case <-time.After(time.Second):
+			t.Fatal("events not released by the replay")
+		}
+	}
+	// Missed events first, then live events, then the reply
+	assert.Equal(t, []string{StoppedEvent, PlayingEvent, ReplayedEvent}, topics)
+	hub.Del(client)
+	assert.NoError(t, hub.Close())
+}
+
+// Sends one event then disconnects
+type sendingClient struct {
+	topicClient
+	sent []byte
+}
+
+func (c *sendingClient) Read() ([]byte, error) {
+	if c.sent == nil {
+		return nil, io.EOF
+	}
+	b := c.sent
+	c.sent = nil
+	return b, nil
+}
+
+func TestHubReleasesHoldOnOtherEvent(t *testing.T) {
+	hub := New()
+	hub.Config = testConfig{buffer: 8, hold: time.Second * 5}
+	raw, _ := json.Marshal(&Event{Topic: StopEvent})
+	client := &sendingClient{topicClient{"1", make(chan string, 8)}, raw}
+	hub.Add(client)
+	ce := <-hub.eventsC
+	assert.Equal(t, StopEvent, ce.Event.Topic)
+	assert.NoError(t, hub.Broadcast(Event{Topic: StoppedEvent}))
+	select {
+	case topic := <-client.topicsC:
+		assert.Equal(t, StoppedEvent, topic)
+	case <-time.After(time.Second):
+		t.Fatal("events held for a client which did not replay")
+	}
+	hub.Del(client)
+	assert.NoError(t, hub.Close())
+}
+
+type testConfig struct {
+	buffer int
+	hold   time.Duration
+}
+
+func (c testConfig) JournalPath() string       { return "" }
+func (c testConfig) JournalSize() int          { return 0 }
+func (c testConfig) HubBuffer() int            { return c.buffer }
+func (c testConfig) HubTimeout() time.Duration { return 0 }
+func (c testConfig) Audit() bool               { return false }
+func (c testConfig) RateLimit() float64        { return 0 }
+func (c testConfig) RateBurst() int            { return 0 }
+func (c testConfig) HubHold() time.Duration    { return c.hold }
+
+// Blocks writes until unblocked, safe for concurrent use
+type blockingClient struct {
+	id       string
+	unblockC chan bool
+	closedC  chan bool
+	lock     sync.Mutex
+	written  int
+}
+
+func (c *blockingClient) ID() string            { return c.id }
+func (c *blockingClient) Read() ([]byte, error) { return nil, io.EOF }
+func (c *blockingClient) Close() error {
+	close(c.closedC)
+	return nil
+}
+func (c *blockingClient) Write(b []byte) (int, error) {
+	<-c.unblockC
+	c.lock.Lock()
+	c.written++
+	c.lock.Unlock()
+	return len(b), nil
+}
+
+func newBlockingClient(id string) *blockingClient {
+	return &blockingClient{
+		id:       id,
+		unblockC: make(chan bool),
+		closedC:  make(chan bool),
+	}
+}
+
+func TestHubDisconnectsLaggingClient(t *testing.T) {
+	hub := New()
+	hub.Config = testConfig{buffer: 2}
+	client := newBlockingClient("slow")
+	hub.Add(client)
+	// One event is being written, two fill the buffer and the last
+	// does not fit
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, hub.Broadcast(Event{Topic: PlayingEvent}))
+	}
+	select {
+	case <-client.closedC:
+	case <-time.After(time.Second):
+		t.Fatal("lagging client not closed")
+	}
+	hub.clientsLock.Lock()
+	_, ok := hub.clients[client.ID()]
+	hub.clientsLock.Unlock()
+	assert.False(t, ok)
+	close(client.unblockC)
+	assert.NoError(t, hub.Close())
+}
+
+// A blocking client which recovers missed events
+type recoveringClient struct {
+	*blockingClient
+	missedC chan bool
+}
+
+func (c *recoveringClient) Missed() {
+	select {
+	case c.missedC <- true:
+	default:
+	}
+}
+
+func TestHubKeepsRecoveringClient(t *testing.T) {
+	hub := New()
+	hub.Config = testConfig{buffer: 2}
+	client := &recoveringClient{newBlockingClient("slow"), make(chan bool, 1)}
+	hub.Add(client)
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, hub.Broadcast(Event{Topic: PlayingEvent}))
+	}
+	select {
+	case <-client.missedC:
+	case <-time.After(time.Second):
+		t.Fatal("client not told of missed events")
+	}
+	hub.clientsLock.Lock()
+	_, ok := hub.clients[client.ID()]
+	hub.clientsLock.Unlock()
+	assert.True(t, ok)
+	close(client.unblockC)
+	hub.Del(client)
+	assert.NoError(t, hub.Close())
+}
+
+func TestHubDelWritesBuffered(t *testing.T) {
+	hub := New()
+	client := newBlockingClient("1")
+	hub.Add(client)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, hub.Broadcast(Event{Topic: PlayingEvent}))
+	}
+	close(client.unblockC)
+	hub.Del(client)
+	assert.Equal(t, 3, client.written)
+	assert.NoError(t, hub.Close())
+}
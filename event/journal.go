@@ -0,0 +1,188 @@
+package event
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"player/logger"
+)
+
+// Events kept by journals created without a configuration
+const journalSize = 1000
+
+// Largest journal line read, events are far smaller
+const maxJournalLine = 1024 * 1024
+
+// A bounded journal of broadcast events numbered in sequence so clients
+// can replay events they missed, appended to a file to survive restarts
+type Journal struct {
+	lock   sync.Mutex
+	size   int     // Events kept
+	seq    uint64  // Sequence number of the last event
+	events []Event // Last events, oldest first
+	path   string
+	file   *os.File
+	lines  int // Lines in the file, compacted once twice the size
+}
+
+// Opens a journal, restoring events and the sequence from the file at
+// path, an empty path keeps events in memory only
+func NewJournal(path string, size int) (*Journal, error) {
+	if size < 1 {
+		size = journalSize
+	}
+	j := &Journal{
+		size: size,
+		path: path,
+	}
+	if path == "" {
+		return j, nil
+	}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	if err := j.compact(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Reads the events in the journal file, skipping lines which cannot be
+// decoded, e.g: a line cut short by a crash
+func (j *Journal) load() error {
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 4096), maxJournalLine)
+	for scanner.Scan() {
+		e := Event{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Seq == 0 {
+			logger.WithError(err).Warn("skipping invalid journal line")
+			continue
+		}
+		j.keep(e)
+	}
+	return scanner.Err()
+}
+
+// Adds an event to the events kept, dropping the oldest
+func (j *Journal) keep(e Event) {
+	if e.Seq > j.seq {
+		j.seq = e.Seq
+	}
+	j.events = append(j.events, e)
+	if len(j.events) > j.size {
+		j.events = append(j.events[:0], j.events[len(j.events)-j.size:]...)
+	}
+}
+
+// Rewrites the journal file with only the events kept
+func (j *Journal) compact() error {
+	tmp := j.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, e := range j.events {
+		if err := j.write(w, e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+	if j.file != nil {
+		j.file.Close()
+	}
+	j.file, err = os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	j.lines = len(j.events)
+	return nil
+}
+
+// Writes an event as a line of json
+func (j *Journal) write(w io.Writer, e Event) error {
+	b, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// Numbers an event with the next sequence number and appends it to the
+// journal, the event is kept in memory even if the file write fails
+func (j *Journal) Append(e *Event) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	e.Seq = j.seq + 1
+	j.keep(*e)
+	if j.file == nil {
+		return nil
+	}
+	if err := j.write(j.file, *e); err != nil {
+		return err
+	}
+	j.lines++
+	if j.lines >= j.size*2 {
+		return j.compact()
+	}
+	return nil
+}
+
+// Returns the sequence number of the last event
+func (j *Journal) Seq() uint64 {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.seq
+}
+
+// Returns the events after a sequence number, oldest first, and false
+// if events after it have already been dropped from the journal
+func (j *Journal) Since(seq uint64) ([]Event, bool) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	oldest := j.seq + 1
+	if len(j.events) > 0 {
+		oldest = j.events[0].Seq
+	}
+	var events []Event
+	for _, e := range j.events {
+		if e.Seq > seq {
+			events = append(events, e)
+		}
+	}
+	return events, seq+1 >= oldest
+}
+
+// Closes the journal file
+func (j *Journal) Close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
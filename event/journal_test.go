@@ -0,0 +1,57 @@
+package event
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "events")
+	j, err := NewJournal(path, 3)
+	assert.NoError(t, err)
+	for i := 0; i < 7; i++ {
+		e := Event{Topic: PlayingEvent}
+		assert.NoError(t, j.Append(&e))
+		assert.Equal(t, uint64(i+1), e.Seq)
+	}
+	// Compacted once twice the size
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(string(b), "\n"))
+	assert.NoError(t, j.Close())
+	// Reopening restores the last events and the sequence
+	j, err = NewJournal(path, 3)
+	assert.NoError(t, err)
+	defer j.Close()
+	assert.Equal(t, uint64(7), j.Seq())
+	tt := []struct {
+		tname    string
+		since    uint64
+		seqs     []uint64
+		complete bool
+	}{
+		{"all kept", 4, []uint64{5, 6, 7}, true},
+		{"some", 5, []uint64{6, 7}, true},
+		{"none", 7, nil, true},
+		{"dropped", 2, []uint64{5, 6, 7}, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			events, complete := j.Since(tc.since)
+			var seqs []uint64
+			for _, e := range events {
+				seqs = append(seqs, e.Seq)
+			}
+			assert.Equal(t, tc.seqs, seqs)
+			assert.Equal(t, tc.complete, complete)
+		})
+	}
+}
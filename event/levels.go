@@ -0,0 +1,78 @@
+package event
+
+import (
+	"encoding/json"
+	"time"
+
+	"player/audio"
+	"player/logger"
+)
+
+const LevelsEvent string = "player:levels"
+
+// Levels held back waiting to be heard before they are dropped
+const levelsQueue = 64
+
+type LevelsPayload struct {
+	RMS   []float64 `json:"rms"`   // Per channel RMS level (0-1)
+	Peak  []float64 `json:"peak"`  // Per channel peak level (0-1)
+	Bands []float64 `json:"bands"` // Spectrum band levels (0-1), low to high frequency
+}
+
+// Holds levels back until the audio they were measured from is heard
+func delayLevels(levels <-chan audio.Levels) <-chan audio.Levels {
+	type delayed struct {
+		due    time.Time
+		levels audio.Levels
+	}
+	pendingC := make(chan delayed, levelsQueue)
+	outC := make(chan audio.Levels)
+	go func() {
+		defer close(pendingC)
+		for l := range levels {
+			select {
+			case pendingC <- delayed{time.Now().Add(audio.Latency()), l}:
+			default: // Publisher has fallen behind, drop the levels
+			}
+		}
+	}()
+	go func() {
+		defer close(outC)
+		for d := range pendingC {
+			time.Sleep(time.Until(d.due))
+			outC <- d.levels
+		}
+	}()
+	return outC
+}
+
+// Writes levels events to the writer until the levels channel is closed,
+// levels are not sent through the hub so event clients are not flooded
+func PublishLevels(levels <-chan audio.Levels, w Writer) {
+	logger.Debug("start levels publisher")
+	defer logger.Debug("exit levels publisher")
+	for l := range delayLevels(levels) {
+		payload, err := json.Marshal(&LevelsPayload{
+			RMS:   l.RMS,
+			Peak:  l.Peak,
+			Bands: l.Bands,
+		})
+		if err != nil {
+			logger.WithError(err).Error("error encoding levels payload")
+			continue
+		}
+		body, err := json.Marshal(&Event{
+			V:       Version,
+			Topic:   LevelsEvent,
+			Created: time.Now().UTC(),
+			Payload: json.RawMessage(payload),
+		})
+		if err != nil {
+			logger.WithError(err).Error("error encoding levels event")
+			continue
+		}
+		if _, err := w.Write(body); err != nil {
+			logger.WithError(err).Error("error publishing levels event")
+		}
+	}
+}
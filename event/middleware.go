@@ -0,0 +1,70 @@
+package event
+
+import (
+	"encoding/json"
+)
+
+// Handles an event from a client
+type HandlerFunc func(ce ClientEvent) error
+
+// Emits an event from the hub, to a single client or broadcast to all
+// subscribed clients if the client is nil
+type EmitFunc func(e Event, to Client) error
+
+// Wraps the handling of incoming events, e.g: to audit or reject them
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Wraps the emitting of outgoing events
+type OutboundMiddleware func(next EmitFunc) EmitFunc
+
+// Adds middleware around the handling of incoming events, middleware
+// added first sees events first
+func Use(m Middleware) { hub.Use(m) }
+func (hub *Hub) Use(m Middleware) {
+	hub.middlewareLock.Lock()
+	hub.inbound = append(hub.inbound, m)
+	hub.middlewareLock.Unlock()
+}
+
+// Adds middleware around the emitting of outgoing events, middleware
+// added first sees events first
+func UseOutbound(m OutboundMiddleware) { hub.UseOutbound(m) }
+func (hub *Hub) UseOutbound(m OutboundMiddleware) {
+	hub.middlewareLock.Lock()
+	hub.outbound = append(hub.outbound, m)
+	hub.middlewareLock.Unlock()
+}
+
+// Handles an incoming event through the middleware
+func (hub *Hub) handleEvent(ce ClientEvent) error {
+	hub.middlewareLock.Lock()
+	h := HandlerFunc(hub.dispatch)
+	for i := len(hub.inbound) - 1; i >= 0; i-- {
+		h = hub.inbound[i](h)
+	}
+	hub.middlewareLock.Unlock()
+	return h(ce)
+}
+
+// Emits an outgoing event through the middleware
+func (hub *Hub) emit(e Event, to Client) error {
+	hub.middlewareLock.Lock()
+	emit := EmitFunc(hub.deliver)
+	for i := len(hub.outbound) - 1; i >= 0; i-- {
+		emit = hub.outbound[i](emit)
+	}
+	hub.middlewareLock.Unlock()
+	return emit(e, to)
+}
+
+// Writes an event to a client or broadcasts it if the client is nil
+func (hub *Hub) deliver(e Event, to Client) error {
+	if to == nil {
+		return hub.broadcast(e)
+	}
+	body, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+	return hub.send(to, body)
+}
@@ -0,0 +1,72 @@
+package event
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHubMiddleware(t *testing.T) {
+	hub := New()
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ce ClientEvent) error {
+				calls = append(calls, name)
+				return next(ce)
+			}
+		}
+	}
+	hub.Use(trace("first"))
+	hub.Use(trace("second"))
+	// Outbound middleware can rewrite events
+	hub.UseOutbound(func(next EmitFunc) EmitFunc {
+		return func(e Event, to Client) error {
+			calls = append(calls, "outbound")
+			e.ReplyTo = "rewritten"
+			return next(e, to)
+		}
+	})
+	client := &testClient{}
+	payload, _ := json.Marshal(&SubscribePayload{Topics: []string{"player:*"}})
+	assert.NoError(t, hub.handleEvent(ClientEvent{client, Event{Topic: SubscribeEvent, Payload: payload}}))
+	assert.Equal(t, []string{"first", "second", "outbound"}, calls)
+	assert.Len(t, client.written, 1)
+	e := Event{}
+	assert.NoError(t, json.Unmarshal(client.written[0], &e))
+	assert.Equal(t, SubscriptionsEvent, e.Topic)
+	assert.Equal(t, "rewritten", e.ReplyTo)
+}
+
+func TestHubRateLimit(t *testing.T) {
+	hub := New()
+	hub.Use(hub.RateLimit(1, 2))
+	hub.Handle("test:noop", func(ce ClientEvent) error { return nil })
+	client := &testClient{}
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, hub.handleEvent(ClientEvent{client, Event{Topic: "test:noop"}}))
+	}
+	// The third event is over the burst
+	assert.Len(t, client.written, 1)
+	e := Event{}
+	assert.NoError(t, json.Unmarshal(client.written[0], &e))
+	assert.Equal(t, ErrorEvent, e.Topic)
+	payload := ErrorPayload{}
+	assert.NoError(t, json.Unmarshal(e.Payload, &payload))
+	assert.Equal(t, CodeRateLimited, payload.Code)
+	assert.True(t, payload.Retryable)
+}
+
+func TestBucket(t *testing.T) {
+	now := time.Now()
+	b := &bucket{tokens: 1, last: now}
+	assert.True(t, b.take(now, 2, 1))
+	assert.False(t, b.take(now, 2, 1))
+	// Refilled at the rate
+	assert.True(t, b.take(now.Add(time.Millisecond*500), 2, 1))
+	// Up to the burst
+	assert.True(t, b.take(now.Add(time.Second*10), 2, 1))
+	assert.False(t, b.take(now.Add(time.Second*10), 2, 1))
+}
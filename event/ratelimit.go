@@ -0,0 +1,69 @@
+package event
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Rate limited error
+var ErrRateLimited = errors.New("rate limited, too many events")
+
+// Buckets not used for this long are forgotten
+const bucketExpiry = time.Minute * 5
+
+// Token bucket refilled at a rate up to a burst
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Takes a token from the bucket, returning false if it is empty
+func (b *bucket) take(now time.Time, rate float64, burst int) bool {
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Limits the events each client may send to a rate per second with a
+// burst, events over the limit are not handled and error back to the
+// client
+func RateLimit(rate float64, burst int) Middleware { return hub.RateLimit(rate, burst) }
+func (hub *Hub) RateLimit(rate float64, burst int) Middleware {
+	if burst < 1 {
+		burst = 1
+	}
+	var lock sync.Mutex
+	buckets := make(map[string]*bucket)
+	allow := func(id string) bool {
+		lock.Lock()
+		defer lock.Unlock()
+		now := time.Now()
+		for k, b := range buckets {
+			if now.Sub(b.last) > bucketExpiry {
+				delete(buckets, k)
+			}
+		}
+		b, ok := buckets[id]
+		if !ok {
+			b = &bucket{tokens: float64(burst), last: now}
+			buckets[id] = b
+		}
+		return b.take(now, rate, burst)
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ce ClientEvent) error {
+			if !allow(ce.Client.ID()) {
+				return hub.replyError(ce, ErrRateLimited)
+			}
+			return next(ce)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package event
+
+import (
+	"encoding/json"
+
+	"player/logger"
+)
+
+// Opens the configured event journal, events broadcast before it is
+// opened are kept in memory only
+func OpenJournal(c Configurer) error { return hub.OpenJournal(c) }
+func (hub *Hub) OpenJournal(c Configurer) error {
+	journal, err := NewJournal(c.JournalPath(), c.JournalSize())
+	if err != nil {
+		return err
+	}
+	hub.clientsLock.Lock()
+	hub.journal = journal
+	hub.clientsLock.Unlock()
+	logger.WithFields(logger.F{
+		"path": c.JournalPath(),
+		"seq":  journal.Seq(),
+	}).Debug("opened event journal")
+	return nil
+}
+
+// Returns the journaled events after a sequence number, oldest first,
+// and false if events after it have already been dropped. Clients which
+// queue events while disconnected replay from here themselves so the
+// replay and their queue are written in one pass on reconnect.
+func Since(seq uint64) ([]Event, bool) { return hub.Since(seq) }
+func (hub *Hub) Since(seq uint64) ([]Event, bool) {
+	return hub.journal.Since(seq)
+}
+
+// The replay event writes the subscribed events a client missed after
+// a sequence number, up to when it was added to the hub, replying with
+// the number of events replayed. Replayed events are written before live
+// events held since the client was added, a replay after the hold
+// expired or after another event is written after the live events
+// already buffered.
+func (hub *Hub) replay(ce ClientEvent) error {
+	logger.Debug("handle replay event")
+	payload := &ReplayPayload{}
+	if err := json.Unmarshal(ce.Event.Payload, payload); err != nil {
+		return hub.replyError(ce, payloadError{err})
+	}
+	id := ce.Client.ID()
+	hub.clientsLock.Lock()
+	joined := hub.joined[id]
+	w := hub.writers[id]
+	events, complete := hub.journal.Since(payload.Since)
+	count := 0
+	for _, e := range events {
+		if e.Seq > joined {
+			break // Already received live
+		}
+		if !hub.subscribed(id, e.Topic) {
+			continue
+		}
+		b, err := json.Marshal(&e)
+		if err != nil {
+			hub.clientsLock.Unlock()
+			return err
+		}
+		count++
+		if w != nil && w.held {
+			w.replayed = append(w.replayed, b)
+			continue
+		}
+		if err := hub.enqueue(ce.Client, b); err != nil {
+			hub.clientsLock.Unlock()
+			return err
+		}
+	}
+	hub.unhold(w)
+	hub.clientsLock.Unlock()
+	return hub.replyEvent(ce, ReplayedEvent, &ReplayedPayload{
+		Since:    payload.Since,
+		Count:    count,
+		Complete: complete,
+	})
+}
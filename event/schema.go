@@ -0,0 +1,301 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Version of the event envelope, events without a version are treated
+// as the first version
+const Version = 1
+
+// JSON Schema of the event envelope
+const envelopeSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "Event",
+	"type": "object",
+	"properties": {
+		"v": {"type": "integer", "minimum": 1, "description": "Envelope version"},
+		"id": {"type": "string", "description": "Optional client id, echoed as replyTo"},
+		"replyTo": {"type": "string", "description": "ID of the event this event responds to"},
+		"seq": {"type": "integer", "minimum": 1, "description": "Journal sequence number of broadcast events"},
+		"topic": {"type": "string", "minLength": 1},
+		"created": {"type": "string", "format": "date-time"},
+		"payload": {"description": "Topic payload, see the topic schemas"}
+	},
+	"required": ["topic"]
+}`
+
+// JSON Schema of topics without a payload
+const emptySchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "EmptyPayload",
+	"description": "No payload"
+}`
+
+const playSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "PlayPayload",
+	"type": "object",
+	"properties": {
+		"providerID": {"type": "string", "minLength": 1, "description": "The provider name (googlemusic, soundcloud)"},
+		"providerTrackID": {"type": "string", "minLength": 1, "description": "The provider track id from the provider"},
+		"playlistID": {"type": "string", "description": "The Playlist ID from the playlist service"},
+		"userID": {"type": "string", "description": "The user who queued the track"}
+	},
+	"required": ["providerID", "providerTrackID"]
+}`
+
+const announceSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "AnnouncePayload",
+	"type": "object",
+	"properties": {
+		"providerID": {"type": "string", "description": "The provider name (googlemusic, soundcloud)"},
+		"providerTrackID": {"type": "string", "description": "The provider track id from the provider"},
+		"file": {"type": "string", "description": "Path to a local .wav or .mp3 file"}
+	}
+}`
+
+const playingSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "PlayingPayload",
+	"type": "object",
+	"properties": {
+		"playlistID": {"type": "string"},
+		"userID": {"type": "string"},
+		"title": {"type": "string"},
+		"artist": {"type": "string"},
+		"album": {"type": "string"},
+		"track": {"type": "integer"},
+		"artworkURL": {"type": "string"}
+	},
+	"required": ["playlistID"]
+}`
+
+const lifecycleSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "LifecyclePayload",
+	"type": "object",
+	"properties": {
+		"playlistID": {"type": "string"},
+		"userID": {"type": "string"},
+		"reason": {"type": "string", "description": "Why the track finished or stopped, or the error code it failed with"},
+		"played": {"type": "integer", "minimum": 0, "description": "Milliseconds of the track played"}
+	},
+	"required": ["playlistID"]
+}`
+
+const outputSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "OutputPayload",
+	"type": "object",
+	"properties": {
+		"backend": {"type": "string", "description": "Backend name, empty for the current backend"},
+		"device": {"type": "string", "description": "Device name or index, empty for the default device"}
+	}
+}`
+
+const outputErrorSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "OutputErrorPayload",
+	"type": "object",
+	"properties": {
+		"backend": {"type": "string"},
+		"device": {"type": "string"},
+		"error": {"type": "string"},
+		"retry": {"type": "string", "description": "Delay before the next attempt to reopen, e.g: 2s"}
+	},
+	"required": ["backend", "error", "retry"]
+}`
+
+const errorSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "ErrorPayload",
+	"type": "object",
+	"properties": {
+		"code": {"type": "string", "description": "Stable error code, e.g: ALREADY_PLAYING"},
+		"error": {"type": "string"},
+		"topic": {"type": "string"},
+		"playlistID": {"type": "string"},
+		"retryable": {"type": "boolean"}
+	},
+	"required": ["code", "error", "retryable"]
+}`
+
+const deadAirSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "DeadAirPayload",
+	"type": "object",
+	"properties": {
+		"playlistID": {"type": "string"}
+	},
+	"required": ["playlistID"]
+}`
+
+const levelsSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "LevelsPayload",
+	"type": "object",
+	"properties": {
+		"rms": {"type": "array", "items": {"type": "number", "minimum": 0, "maximum": 1}},
+		"peak": {"type": "array", "items": {"type": "number", "minimum": 0, "maximum": 1}},
+		"bands": {"type": "array", "items": {"type": "number", "minimum": 0, "maximum": 1}}
+	},
+	"required": ["rms", "peak", "bands"]
+}`
+
+const subscribeSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "SubscribePayload",
+	"type": "object",
+	"properties": {
+		"topics": {"type": "array", "items": {"type": "string", "minLength": 1}, "description": "Topic patterns, e.g: player:*"}
+	},
+	"required": ["topics"]
+}`
+
+const replaySchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "ReplayPayload",
+	"type": "object",
+	"properties": {
+		"since": {"type": "integer", "minimum": 0, "description": "Sequence number of the last event received"}
+	},
+	"required": ["since"]
+}`
+
+const replayedSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "ReplayedPayload",
+	"type": "object",
+	"properties": {
+		"since": {"type": "integer", "minimum": 0},
+		"count": {"type": "integer", "minimum": 0},
+		"complete": {"type": "boolean", "description": "False if events after since were dropped from the journal"}
+	},
+	"required": ["since", "count", "complete"]
+}`
+
+const statusReportSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "StatusReportPayload",
+	"type": "object",
+	"description": "Status of each part of the player by name",
+	"properties": {
+		"websocket": {
+			"type": "object",
+			"description": "Queue of events written while the websocket is disconnected",
+			"properties": {
+				"size": {"type": "integer"},
+				"depth": {"type": "integer"},
+				"dropped": {"type": "integer"},
+				"coalesced": {"type": "integer"}
+			}
+		}
+	}
+}`
+
+const topicsSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"title": "TopicsPayload",
+	"type": "object",
+	"properties": {
+		"topics": {"type": "array", "items": {"type": "string"}, "description": "Handled topics"}
+	},
+	"required": ["topics"]
+}`
+
+// JSON Schemas of topic payloads
+var schemas = map[string]string{
+	PlayerReadyEvent:   emptySchema,
+	PlayerOfflineEvent: emptySchema,
+	PlayEvent:          playSchema,
+	LoadingEvent:       lifecycleSchema,
+	BufferingEvent:     lifecycleSchema,
+	PlayingEvent:       playingSchema,
+	StopEvent:          emptySchema,
+	StoppedEvent:       lifecycleSchema,
+	PauseEvent:         emptySchema,
+	PausedEvent:        lifecycleSchema,
+	ResumeEvent:        emptySchema,
+	ResumedEvent:       lifecycleSchema,
+	FinishedEvent:      lifecycleSchema,
+	FailedEvent:        lifecycleSchema,
+	ErrorEvent:         errorSchema,
+	DeadAirEvent:       deadAirSchema,
+	AnnounceEvent:      announceSchema,
+	OutputEvent:        outputSchema,
+	OutputChangedEvent: outputSchema,
+	OutputErrorEvent:   outputErrorSchema,
+	LevelsEvent:        levelsSchema,
+	SubscribeEvent:     subscribeSchema,
+	UnsubscribeEvent:   subscribeSchema,
+	SubscriptionsEvent: subscribeSchema,
+	ReplayEvent:        replaySchema,
+	ReplayedEvent:      replayedSchema,
+	StatusEvent:        emptySchema,
+	StatusReportEvent:  statusReportSchema,
+	TopicsEvent:        emptySchema,
+	TopicsListEvent:    topicsSchema,
+}
+
+// Parsed topic payload schemas used for validation
+var validators = make(map[string]*schema)
+
+func init() {
+	for topic, doc := range schemas {
+		s := &schema{}
+		if err := json.Unmarshal([]byte(doc), s); err != nil {
+			panic(fmt.Sprintf("invalid %s schema: %s", topic, err))
+		}
+		validators[topic] = s
+	}
+}
+
+// The envelope schema and the payload schema of every topic
+type SchemaDocument struct {
+	Envelope json.RawMessage            `json:"envelope"`
+	Topics   map[string]json.RawMessage `json:"topics"`
+}
+
+// Returns the event schemas
+func Schemas() SchemaDocument {
+	doc := SchemaDocument{
+		Envelope: json.RawMessage(envelopeSchema),
+		Topics:   make(map[string]json.RawMessage),
+	}
+	for topic, s := range schemas {
+		doc.Topics[topic] = json.RawMessage(s)
+	}
+	return doc
+}
+
+// Returns the payload schema of a topic, false if the topic is unknown
+func Schema(topic string) (json.RawMessage, bool) {
+	s, ok := schemas[topic]
+	return json.RawMessage(s), ok
+}
+
+// Validates an incoming event, events with an unsupported version or a
+// payload not matching its topic schema are invalid, topics without a
+// schema are not validated
+func Validate(e Event) error {
+	if e.V > Version {
+		return ErrVersion
+	}
+	s, ok := validators[e.Topic]
+	if !ok {
+		return nil
+	}
+	var v interface{}
+	if len(e.Payload) > 0 {
+		if err := json.Unmarshal(e.Payload, &v); err != nil {
+			return payloadError{err}
+		}
+	}
+	if err := s.validate(v, "payload"); err != nil {
+		return payloadError{err}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package event
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	tt := []struct {
+		tname string
+		event Event
+		err   string
+	}{
+		{"valid", Event{Topic: PlayEvent, Payload: json.RawMessage(`{"providerID":"soundcloud","providerTrackID":"1"}`)}, ""},
+		{"versioned", Event{V: Version, Topic: PauseEvent}, ""},
+		{"unversioned", Event{Topic: PauseEvent}, ""},
+		{"future version", Event{V: Version + 1, Topic: PauseEvent}, ErrVersion.Error()},
+		{"unknown topic", Event{Topic: "foo:bar", Payload: json.RawMessage(`1`)}, ""},
+		{"no payload", Event{Topic: PlayEvent}, "invalid payload: payload must be an object"},
+		{"malformed", Event{Topic: PlayEvent, Payload: json.RawMessage(`{`)}, "invalid payload: unexpected end of JSON input"},
+		{"required", Event{Topic: PlayEvent, Payload: json.RawMessage(`{"providerID":"soundcloud"}`)}, "invalid payload: payload.providerTrackID is required"},
+		{"empty", Event{Topic: PlayEvent, Payload: json.RawMessage(`{"providerID":"","providerTrackID":"1"}`)}, "invalid payload: payload.providerID must be at least 1 characters"},
+		{"type", Event{Topic: OutputEvent, Payload: json.RawMessage(`{"device":1}`)}, "invalid payload: payload.device must be a string"},
+		{"item", Event{Topic: SubscribeEvent, Payload: json.RawMessage(`{"topics":["player:*",2]}`)}, "invalid payload: payload.topics[1] must be a string"},
+		{"integer", Event{Topic: FinishedEvent, Payload: json.RawMessage(`{"playlistID":"1","played":1.5}`)}, "invalid payload: payload.played must be an integer"},
+		{"maximum", Event{Topic: LevelsEvent, Payload: json.RawMessage(`{"rms":[2],"peak":[],"bands":[]}`)}, "invalid payload: payload.rms[0] must be at most 1"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			err := Validate(tc.event)
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	doc := Schemas()
+	var v interface{}
+	assert.NoError(t, json.Unmarshal(doc.Envelope, &v))
+	for topic, s := range doc.Topics {
+		assert.NoError(t, json.Unmarshal(s, &v), topic)
+	}
+	_, ok := Schema(PlayEvent)
+	assert.True(t, ok)
+	_, ok = Schema("foo:bar")
+	assert.False(t, ok)
+}
@@ -0,0 +1,35 @@
+package event
+
+import (
+	"sort"
+
+	"player/logger"
+)
+
+// Returns the status of a part of the player, e.g: queue statistics
+type StatusFunc func() interface{}
+
+// Adds a named status reported in reply to status events
+func AddStatus(name string, f StatusFunc) { hub.AddStatus(name, f) }
+func (hub *Hub) AddStatus(name string, f StatusFunc) {
+	hub.statusLock.Lock()
+	hub.statuses[name] = f
+	hub.statusLock.Unlock()
+}
+
+// The status event replies with the status of each part of the player
+func (hub *Hub) status(ce ClientEvent) error {
+	logger.Debug("handle status event")
+	hub.statusLock.Lock()
+	names := make([]string, 0, len(hub.statuses))
+	for name := range hub.statuses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	payload := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		payload[name] = hub.statuses[name]()
+	}
+	hub.statusLock.Unlock()
+	return hub.replyEvent(ce, StatusReportEvent, payload)
+}
@@ -0,0 +1,94 @@
+package event
+
+import (
+	"encoding/json"
+	"path"
+
+	"player/logger"
+)
+
+// Returns true if a client receives broadcasts of a topic, clients
+// receive every topic until they subscribe, the clients lock must be held
+func (hub *Hub) subscribed(id, topic string) bool {
+	patterns, ok := hub.subscriptions[id]
+	if !ok {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, topic); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// The subscribe event adds topic patterns, e.g: player:*, to those
+// broadcast to the client, replying with the client's subscriptions
+func (hub *Hub) subscribe(ce ClientEvent) error {
+	logger.Debug("handle subscribe event")
+	payload := &SubscribePayload{}
+	if err := hub.topics(ce, payload); err != nil {
+		return hub.replyError(ce, err)
+	}
+	hub.clientsLock.Lock()
+	patterns := hub.subscriptions[ce.Client.ID()]
+	for _, topic := range payload.Topics {
+		if !contains(patterns, topic) {
+			patterns = append(patterns, topic)
+		}
+	}
+	hub.subscriptions[ce.Client.ID()] = patterns
+	hub.clientsLock.Unlock()
+	return hub.replySubscriptions(ce, patterns)
+}
+
+// The unsubscribe event removes topic patterns from those broadcast to
+// the client, replying with the client's remaining subscriptions
+func (hub *Hub) unsubscribe(ce ClientEvent) error {
+	logger.Debug("handle unsubscribe event")
+	payload := &SubscribePayload{}
+	if err := hub.topics(ce, payload); err != nil {
+		return hub.replyError(ce, err)
+	}
+	hub.clientsLock.Lock()
+	var patterns []string
+	for _, pattern := range hub.subscriptions[ce.Client.ID()] {
+		if !contains(payload.Topics, pattern) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	hub.subscriptions[ce.Client.ID()] = patterns
+	hub.clientsLock.Unlock()
+	return hub.replySubscriptions(ce, patterns)
+}
+
+// Decodes a subscription payload, checking each topic pattern is valid
+func (hub *Hub) topics(ce ClientEvent, payload *SubscribePayload) error {
+	if err := json.Unmarshal(ce.Event.Payload, payload); err != nil {
+		return payloadError{err}
+	}
+	for _, topic := range payload.Topics {
+		if _, err := path.Match(topic, ""); err != nil {
+			return payloadError{err}
+		}
+	}
+	return nil
+}
+
+// Writes the client's subscriptions in reply to a subscription event
+func (hub *Hub) replySubscriptions(ce ClientEvent, patterns []string) error {
+	if patterns == nil {
+		patterns = []string{}
+	}
+	return hub.replyEvent(ce, SubscriptionsEvent, &SubscribePayload{Topics: patterns})
+}
+
+// Returns true if a string is in a list
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
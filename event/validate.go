@@ -0,0 +1,105 @@
+package event
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// The subset of JSON Schema used by the topic schemas, other keywords
+// are documentation only
+type schema struct {
+	Type       string             `json:"type"`
+	Properties map[string]*schema `json:"properties"`
+	Required   []string           `json:"required"`
+	Items      *schema            `json:"items"`
+	MinLength  *int               `json:"minLength"`
+	Minimum    *float64           `json:"minimum"`
+	Maximum    *float64           `json:"maximum"`
+}
+
+// A value not matching its schema
+type SchemaError struct {
+	Path   string // Location of the value, e.g: payload.topics[0]
+	Reason string
+}
+
+func (e *SchemaError) Error() string {
+	return e.Path + " " + e.Reason
+}
+
+// Validates a decoded JSON value against the schema
+func (s *schema) validate(v interface{}, path string) error {
+	invalid := func(format string, a ...interface{}) error {
+		return &SchemaError{path, fmt.Sprintf(format, a...)}
+	}
+	switch s.Type {
+	case "":
+		return nil
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return invalid("must be an object")
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return &SchemaError{path + "." + name, "is required"}
+			}
+		}
+		// Checked in order so the same error is always reported
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value, ok := obj[name]
+			if !ok {
+				continue
+			}
+			if err := s.Properties[name].validate(value, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return invalid("must be an array")
+		}
+		if s.Items == nil {
+			return nil
+		}
+		for i, item := range arr {
+			if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return invalid("must be a string")
+		}
+		if s.MinLength != nil && len(str) < *s.MinLength {
+			return invalid("must be at least %d characters", *s.MinLength)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return invalid("must be a boolean")
+		}
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok {
+			return invalid("must be a number")
+		}
+		if s.Type == "integer" && n != math.Trunc(n) {
+			return invalid("must be an integer")
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			return invalid("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			return invalid("must be at most %v", *s.Maximum)
+		}
+	}
+	return nil
+}
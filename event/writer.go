@@ -0,0 +1,211 @@
+package event
+
+import (
+	"errors"
+	"time"
+
+	"player/logger"
+)
+
+// Client disconnect reasons
+var (
+	ErrLagging = errors.New("client fell behind, event buffer full")
+)
+
+// Implemented by clients which can bound the time taken by a write
+type Deadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// Implemented by clients which recover events they miss from the journal,
+// they are never disconnected for falling behind, events which do not
+// fit their buffer are dropped and the client told it missed them
+type Recoverer interface {
+	Missed()
+}
+
+// Writes events to a client in order from its own buffer so a slow
+// client does not hold up broadcasts to others
+type writer struct {
+	client   Client
+	timeout  time.Duration
+	hold     time.Duration
+	held     bool      // Live events are held for a replay, guarded by the clients lock
+	replayed [][]byte  // Replayed events written before held events, guarded by the clients lock
+	holdC    chan bool // Closed once replayed events are ready
+	queueC   chan []byte
+	stopC    chan bool // Closed to write what is buffered and exit
+	doneC    chan bool // Closed once the writer has exited
+}
+
+// Writes buffered events until stopped or the hub is closed, clients
+// which fail a write are disconnected
+func (hub *Hub) drain(w *writer) {
+	defer hub.closeWg.Done()
+	defer close(w.doneC)
+	if err := hub.release(w); err != nil {
+		hub.lag(w, err)
+		return
+	}
+	for {
+		select {
+		case <-hub.closeC:
+			return
+		case b := <-w.queueC:
+			if err := w.write(b); err != nil {
+				hub.lag(w, err)
+				return
+			}
+		case <-w.stopC:
+			for {
+				select {
+				case b := <-w.queueC:
+					if err := w.write(b); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Holds live events until the client replays the events it missed or
+// the hold expires, then writes the replayed events
+func (hub *Hub) release(w *writer) error {
+	hub.clientsLock.Lock()
+	held := w.held
+	hub.clientsLock.Unlock()
+	if held {
+		timer := time.NewTimer(w.hold)
+		select {
+		case <-w.holdC:
+		case <-timer.C:
+		case <-w.stopC:
+		case <-hub.closeC:
+		}
+		timer.Stop()
+	}
+	hub.clientsLock.Lock()
+	w.held = false
+	replayed := w.replayed
+	w.replayed = nil
+	hub.clientsLock.Unlock()
+	for _, b := range replayed {
+		if err := w.write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stops holding live events for a client, must be called with the
+// clients lock held
+func (hub *Hub) unhold(w *writer) {
+	if w == nil || !w.held {
+		return
+	}
+	w.held = false
+	close(w.holdC)
+}
+
+// Writes an event to the client within the write timeout
+func (w *writer) write(b []byte) error {
+	if d, ok := w.client.(Deadliner); ok && w.timeout > 0 {
+		if err := d.SetWriteDeadline(time.Now().Add(w.timeout)); err != nil {
+			return err
+		}
+	}
+	_, err := w.client.Write(b)
+	return err
+}
+
+// Buffers an event for a client, disconnecting it if its buffer is
+// full unless it recovers missed events, clients without a writer are
+// written to directly. Must be called with the clients lock held.
+func (hub *Hub) enqueue(client Client, b []byte) error {
+	w, ok := hub.writers[client.ID()]
+	if !ok {
+		_, err := client.Write(b)
+		return err
+	}
+	select {
+	case w.queueC <- b:
+		return nil
+	default:
+		if r, ok := client.(Recoverer); ok {
+			r.Missed()
+			return nil
+		}
+		hub.disconnect(w, ErrLagging)
+		return ErrLagging
+	}
+}
+
+// Buffers an event for a client
+func (hub *Hub) send(client Client, b []byte) error {
+	hub.clientsLock.Lock()
+	defer hub.clientsLock.Unlock()
+	return hub.enqueue(client, b)
+}
+
+// Disconnects the client of a writer which failed a write
+func (hub *Hub) lag(w *writer, err error) {
+	hub.clientsLock.Lock()
+	defer hub.clientsLock.Unlock()
+	hub.disconnect(w, err)
+}
+
+// Removes a client from the hub and closes it, must be called with the
+// clients lock held
+func (hub *Hub) disconnect(w *writer, reason error) {
+	id := w.client.ID()
+	if hub.writers[id] != w {
+		return // Already removed
+	}
+	logger.WithError(reason).WithField("client", id).Warn("disconnecting hub client")
+	hub.remove(w.client)
+	// Clients delete themselves from the hub on close
+	go func() {
+		if err := w.client.Close(); err != nil {
+			logger.WithError(err).WithField("client", id).Error("error closing hub client")
+		}
+	}()
+}
+
+// Removes a client and its writer from the hub, returning the writer,
+// must be called with the clients lock held
+func (hub *Hub) remove(client Client) *writer {
+	id := client.ID()
+	hub.clients.Del(client)
+	delete(hub.subscriptions, id)
+	delete(hub.joined, id)
+	w, ok := hub.writers[id]
+	if !ok {
+		return nil
+	}
+	delete(hub.writers, id)
+	close(w.stopC)
+	return w
+}
+
+// Constructs a writer for a client and starts it, must be called with
+// the clients lock held
+func (hub *Hub) addWriter(client Client) {
+	w := &writer{
+		client:  client,
+		timeout: hub.Config.HubTimeout(),
+		hold:    hub.Config.HubHold(),
+		holdC:   make(chan bool),
+		queueC:  make(chan []byte, hub.Config.HubBuffer()),
+		stopC:   make(chan bool),
+		doneC:   make(chan bool),
+	}
+	// Clients which recover missed events themselves never replay
+	_, recovers := client.(Recoverer)
+	w.held = w.hold > 0 && !recovers
+	hub.writers[client.ID()] = w
+	hub.closeWg.Add(1)
+	go hub.drain(w)
+}
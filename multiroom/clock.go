@@ -0,0 +1,67 @@
+package multiroom
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock samples kept, the sample with the lowest round trip is used
+const clockSamples = 8
+
+// A clock offset measurement
+type clockSample struct {
+	offset time.Duration // Leader clock minus local clock
+	delay  time.Duration // Round trip network delay
+}
+
+// Computes an NTP style offset and round trip delay from the time a
+// request was sent (t1), received by the leader (t2), replied to by the
+// leader (t3) and the reply received (t4)
+func measure(t1, t2, t3, t4 time.Time) clockSample {
+	return clockSample{
+		offset: (t2.Sub(t1) + t3.Sub(t4)) / 2,
+		delay:  t4.Sub(t1) - t3.Sub(t2),
+	}
+}
+
+// The shared clock, the leader's clock as estimated locally
+type Clock struct {
+	lock    sync.RWMutex
+	samples []clockSample
+	offset  time.Duration
+}
+
+// Adds a measurement, updating the offset from the measurement with the
+// lowest round trip delay as it is least affected by queuing
+func (c *Clock) add(s clockSample) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.samples = append(c.samples, s)
+	if len(c.samples) > clockSamples {
+		c.samples = c.samples[1:]
+	}
+	best := c.samples[0]
+	for _, s := range c.samples[1:] {
+		if s.delay < best.delay {
+			best = s
+		}
+	}
+	c.offset = best.offset
+}
+
+// Returns the offset of the shared clock from the local clock
+func (c *Clock) Offset() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.offset
+}
+
+// Returns the current shared time
+func (c *Clock) Now() time.Time {
+	return time.Now().Add(c.Offset())
+}
+
+// Converts a shared time to local time
+func (c *Clock) Local(t time.Time) time.Time {
+	return t.Add(-c.Offset())
+}
@@ -0,0 +1,48 @@
+package multiroom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeasure(t *testing.T) {
+	tt := []struct {
+		tname  string
+		offset time.Duration // Leader clock minus local clock
+		out    time.Duration // Network delay to the leader
+		back   time.Duration // Network delay from the leader
+	}{
+		{"ahead", time.Second, time.Millisecond * 5, time.Millisecond * 5},
+		{"behind", -time.Second, time.Millisecond * 5, time.Millisecond * 5},
+		{"asymmetric", time.Second, time.Millisecond * 2, time.Millisecond * 8},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			t1 := time.Unix(1000, 0)
+			t2 := t1.Add(tc.out + tc.offset)
+			t3 := t2.Add(time.Millisecond)
+			t4 := t3.Add(tc.back - tc.offset)
+			s := measure(t1, t2, t3, t4)
+			assert.Equal(t, tc.out+tc.back, s.delay)
+			// Asymmetric routes are out by half the difference
+			assert.Equal(t, tc.offset+(tc.out-tc.back)/2, s.offset)
+		})
+	}
+}
+
+func TestClockLowestDelay(t *testing.T) {
+	c := &Clock{}
+	c.add(clockSample{offset: time.Millisecond * 30, delay: time.Millisecond * 40})
+	c.add(clockSample{offset: time.Millisecond * 10, delay: time.Millisecond * 2})
+	c.add(clockSample{offset: time.Millisecond * 20, delay: time.Millisecond * 20})
+	assert.Equal(t, time.Millisecond*10, c.Offset())
+	// The best sample is forgotten once enough newer samples are added
+	for i := 0; i < clockSamples; i++ {
+		c.add(clockSample{offset: time.Millisecond * 20, delay: time.Millisecond * 20})
+	}
+	assert.Equal(t, time.Millisecond*20, c.Offset())
+	now := c.Now()
+	assert.Equal(t, now.Add(-time.Millisecond*20), c.Local(now))
+}
@@ -0,0 +1,71 @@
+package multiroom
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	vMode       = "multiroom.mode"
+	vAddress    = "multiroom.address"
+	vDelay      = "multiroom.delay"
+	vInterval   = "multiroom.interval"
+	vMaxAdjust  = "multiroom.maxadjust"
+	vCorrection = "multiroom.correction"
+)
+
+func init() {
+	viper.SetDefault(vAddress, ":5005")
+	viper.SetDefault(vDelay, "500ms")
+	viper.SetDefault(vInterval, "2s")
+	viper.SetDefault(vMaxAdjust, 0.002)
+	viper.SetDefault(vCorrection, "5s")
+	viper.BindEnv(vMode, vAddress, vDelay, vInterval, vMaxAdjust, vCorrection)
+}
+
+type Configurer interface {
+	Mode() string
+	Address() string
+	Delay() time.Duration
+	Interval() time.Duration
+	MaxAdjust() float64
+	Correction() time.Duration
+}
+
+type Config struct{}
+
+// Multi-room mode, leader, follower or empty to play alone
+func (c Config) Mode() string {
+	return viper.GetString(vMode)
+}
+
+// Address the leader listens on, or the leader address for followers
+func (c Config) Address() string {
+	return viper.GetString(vAddress)
+}
+
+// How far ahead the leader schedules commands, long enough for
+// followers to receive them and load tracks
+func (c Config) Delay() time.Duration {
+	return viper.GetDuration(vDelay)
+}
+
+// Interval between clock synchronisations
+func (c Config) Interval() time.Duration {
+	return viper.GetDuration(vInterval)
+}
+
+// Largest fractional playback rate adjustment used to correct drift
+func (c Config) MaxAdjust() float64 {
+	return viper.GetFloat64(vMaxAdjust)
+}
+
+// Time over which drift is corrected
+func (c Config) Correction() time.Duration {
+	return viper.GetDuration(vCorrection)
+}
+
+func NewConfig() Config {
+	return Config{}
+}
@@ -0,0 +1,108 @@
+package multiroom
+
+import (
+	"sync"
+	"time"
+
+	"player/audio"
+	"player/logger"
+	"player/player"
+)
+
+// Drift larger than this is logged, it will take a while to correct
+const driftWarn = time.Millisecond * 100
+
+// Tracks where the current track should be against the shared clock and
+// adjusts the playback rate to keep it there
+type Drift struct {
+	Config   Configurer
+	clock    *Clock
+	lock     sync.Mutex
+	start    time.Time // Shared time the track started, moved on by pauses
+	pausedAt time.Time // Shared time the track paused, zero if not paused
+	playing  bool
+}
+
+// Records the track started at a shared time
+func (d *Drift) Start(at time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	audio.ResetPosition()
+	audio.SetRate(1)
+	d.start, d.pausedAt, d.playing = at, time.Time{}, true
+}
+
+// Records the track paused at a shared time
+func (d *Drift) Pause(at time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.pausedAt = at
+}
+
+// Records the track resumed at a shared time
+func (d *Drift) Resume(at time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if !d.pausedAt.IsZero() {
+		d.start = d.start.Add(at.Sub(d.pausedAt))
+		d.pausedAt = time.Time{}
+	}
+}
+
+// Records the track stopped
+func (d *Drift) Stop() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.playing = false
+	audio.SetRate(1)
+}
+
+// Adjusts the playback rate for the current drift, the rate is
+// proportional to the drift so it is corrected over the correction time
+func (d *Drift) correct() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if !d.playing || !d.pausedAt.IsZero() || !player.IsPlaying() {
+		return
+	}
+	elapsed := d.clock.Now().Sub(d.start)
+	if elapsed < 0 {
+		return // Not started yet
+	}
+	drift := audio.Position() - audio.Latency() - elapsed // Positive when ahead
+	adjust := -drift.Seconds() / d.Config.Correction().Seconds()
+	if max := d.Config.MaxAdjust(); adjust > max {
+		adjust = max
+	} else if adjust < -max {
+		adjust = -max
+	}
+	f := logger.F{"drift": drift, "rate": 1 + adjust}
+	if drift > driftWarn || drift < -driftWarn {
+		logger.WithFields(f).Warn("multi-room playback drifting")
+	} else {
+		logger.WithFields(f).Debug("multi-room drift correction")
+	}
+	audio.SetRate(1 + adjust)
+}
+
+// Corrects drift every second until closed
+func (d *Drift) Run(closeC <-chan bool) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closeC:
+			return
+		case <-ticker.C:
+			d.correct()
+		}
+	}
+}
+
+// Constructs drift correction against a shared clock
+func NewDrift(c Configurer, clock *Clock) *Drift {
+	return &Drift{
+		Config: c,
+		clock:  clock,
+	}
+}
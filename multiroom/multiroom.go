@@ -0,0 +1,414 @@
+// Synchronised multi-room playback, a leader schedules commands against
+// a shared clock and followers carry them out at the same instant
+
+package multiroom
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"player/audio"
+	"player/logger"
+	"player/player"
+
+	"github.com/rs/xid"
+)
+
+// Global multi-room node, plays alone until started
+var node = &Node{
+	Config: NewConfig(),
+	clock:  &Clock{},
+	seen:   make(map[string]time.Time),
+	closeC: make(chan bool),
+}
+
+// Errors
+var (
+	ErrFollowing   = errors.New("following a leader, commands must be sent to the leader")
+	ErrUnknownMode = errors.New("unknown multi-room mode, must be leader or follower")
+)
+
+// Message types
+const (
+	msgSync   = "sync"
+	msgPlay   = "play"
+	msgPause  = "pause"
+	msgResume = "resume"
+	msgStop   = "stop"
+)
+
+// Times a command is sent, UDP may drop packets
+const sendRepeat = 3
+
+// Maximum size of a message
+const maxMessageSize = 1024
+
+// A message between leader and followers, times are unix nanoseconds
+type message struct {
+	Type            string `json:"type"`
+	ID              string `json:"id,omitempty"`
+	T1              int64  `json:"t1,omitempty"`
+	T2              int64  `json:"t2,omitempty"`
+	T3              int64  `json:"t3,omitempty"`
+	At              int64  `json:"at,omitempty"`
+	ProviderName    string `json:"providerName,omitempty"`
+	ProviderTrackID string `json:"providerTrackID,omitempty"`
+	PlaylistID      string `json:"playlistID,omitempty"`
+	UserID          string `json:"userID,omitempty"`
+}
+
+// A multi-room node, leader, follower or playing alone
+type Node struct {
+	Config Configurer
+	// Unexported fields
+	mode      string
+	clock     *Clock
+	drift     *Drift
+	conn      *net.UDPConn
+	leader    *net.UDPAddr // Follower only
+	lock      sync.Mutex
+	followers map[string]*follower // Leader only
+	seen      map[string]time.Time // Command ids already handled
+	closeC    chan bool
+	closeWg   sync.WaitGroup
+}
+
+// A follower known to the leader
+type follower struct {
+	addr *net.UDPAddr
+	last time.Time
+}
+
+// Starts the global node in the configured mode
+func Start() error { return node.Start() }
+
+// Starts the node, listening for followers or syncing with a leader
+func (n *Node) Start() error {
+	n.mode = n.Config.Mode()
+	switch n.mode {
+	case "":
+		return nil
+	case "leader":
+		addr, err := net.ResolveUDPAddr("udp", n.Config.Address())
+		if err != nil {
+			return err
+		}
+		conn, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			return err
+		}
+		n.conn = conn
+		n.followers = make(map[string]*follower)
+	case "follower":
+		addr, err := net.ResolveUDPAddr("udp", n.Config.Address())
+		if err != nil {
+			return err
+		}
+		conn, err := net.ListenUDP("udp", nil)
+		if err != nil {
+			return err
+		}
+		n.conn = conn
+		n.leader = addr
+		n.closeWg.Add(1)
+		go func() {
+			defer n.closeWg.Done()
+			n.sync()
+		}()
+	default:
+		return ErrUnknownMode
+	}
+	n.drift = NewDrift(n.Config, n.clock)
+	n.closeWg.Add(2)
+	go func() {
+		defer n.closeWg.Done()
+		n.read()
+	}()
+	go func() {
+		defer n.closeWg.Done()
+		n.drift.Run(n.closeC)
+	}()
+	logger.WithFields(logger.F{
+		"mode":    n.mode,
+		"address": n.Config.Address(),
+	}).Info("multi-room playback started")
+	return nil
+}
+
+// Returns true if following a leader
+func Following() bool { return node.Following() }
+func (n *Node) Following() bool {
+	return n.mode == "follower"
+}
+
+// Plays a track, on the leader all followers start at the same time
+func Play(c player.LoadTrackConfig) error { return node.Play(c) }
+func (n *Node) Play(c player.LoadTrackConfig) error {
+	switch n.mode {
+	case "":
+		return player.Play(c)
+	case "follower":
+		return ErrFollowing
+	}
+	if player.IsPlaying() {
+		return player.ErrPlaying
+	}
+	// Load before scheduling so a bad track errors back to the client
+	if _, err := player.LoadTrack(c); err != nil {
+		return err
+	}
+	n.command(message{
+		Type:            msgPlay,
+		ProviderName:    c.ProviderName,
+		ProviderTrackID: c.ProviderTrackID,
+		PlaylistID:      c.PlaylistID,
+		UserID:          c.UserID,
+	})
+	return nil
+}
+
+// Pauses playback, returns false if not playing or already paused
+func Pause() bool { return node.Pause() }
+func (n *Node) Pause() bool {
+	switch n.mode {
+	case "":
+		return player.Pause()
+	case "follower":
+		return false
+	}
+	if !player.IsPlaying() || player.IsPaused() {
+		return false
+	}
+	n.command(message{Type: msgPause})
+	return true
+}
+
+// Resumes playback, returns false if not playing or not paused
+func Resume() bool { return node.Resume() }
+func (n *Node) Resume() bool {
+	switch n.mode {
+	case "":
+		return player.Resume()
+	case "follower":
+		return false
+	}
+	if !player.IsPlaying() || !player.IsPaused() {
+		return false
+	}
+	n.command(message{Type: msgResume})
+	return true
+}
+
+// Stops playback, returns false if not playing
+func Stop() bool { return node.Stop() }
+func (n *Node) Stop() bool {
+	switch n.mode {
+	case "":
+		return player.Stop()
+	case "follower":
+		return false
+	}
+	if !player.IsPlaying() {
+		return false
+	}
+	n.command(message{Type: msgStop})
+	return true
+}
+
+// Schedules a command ahead of the shared clock, sends it to followers
+// and carries it out locally at the same time
+func (n *Node) command(msg message) {
+	msg.ID = xid.New().String()
+	msg.At = n.clock.Now().Add(n.Config.Delay()).UnixNano()
+	n.lock.Lock()
+	expiry := time.Now().Add(-n.Config.Interval() * 3)
+	for key, f := range n.followers {
+		if f.last.Before(expiry) {
+			logger.WithField("follower", key).Info("multi-room follower lost")
+			delete(n.followers, key)
+			continue
+		}
+		n.send(msg, f.addr)
+	}
+	n.lock.Unlock()
+	n.schedule(msg)
+}
+
+// Sends a message, commands are repeated in case they are dropped
+func (n *Node) send(msg message, addr *net.UDPAddr) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		logger.WithError(err).Error("multi-room message marshal error")
+		return
+	}
+	repeat := sendRepeat
+	if msg.Type == msgSync {
+		repeat = 1
+	}
+	for i := 0; i < repeat; i++ {
+		if _, err := n.conn.WriteToUDP(b, addr); err != nil {
+			logger.WithError(err).Warn("multi-room send error")
+			return
+		}
+	}
+}
+
+// Sends a sync request to the leader every interval, also registering
+// this follower with the leader
+func (n *Node) sync() {
+	ticker := time.NewTicker(n.Config.Interval())
+	defer ticker.Stop()
+	for {
+		n.send(message{
+			Type: msgSync,
+			T1:   time.Now().UnixNano(),
+		}, n.leader)
+		select {
+		case <-n.closeC:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reads messages until the connection is closed
+func (n *Node) read() {
+	b := make([]byte, maxMessageSize)
+	for {
+		i, addr, err := n.conn.ReadFromUDP(b)
+		now := time.Now()
+		if err != nil {
+			select {
+			case <-n.closeC:
+				return
+			default:
+			}
+			logger.WithError(err).Warn("multi-room read error")
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal(b[:i], &msg); err != nil {
+			logger.WithError(err).Debug("multi-room message unmarshal error")
+			continue
+		}
+		switch {
+		case msg.Type == msgSync && n.mode == "leader":
+			n.reply(msg, addr, now)
+		case msg.Type == msgSync:
+			n.clock.add(measure(
+				time.Unix(0, msg.T1),
+				time.Unix(0, msg.T2),
+				time.Unix(0, msg.T3),
+				now))
+		case n.mode == "follower" && n.first(msg.ID):
+			n.schedule(msg)
+		}
+	}
+}
+
+// Replies to a follower sync request and registers the follower
+func (n *Node) reply(msg message, addr *net.UDPAddr, received time.Time) {
+	n.lock.Lock()
+	key := addr.String()
+	if _, ok := n.followers[key]; !ok {
+		logger.WithField("follower", key).Info("multi-room follower joined")
+	}
+	n.followers[key] = &follower{addr: addr, last: received}
+	n.lock.Unlock()
+	msg.T2 = received.UnixNano()
+	msg.T3 = time.Now().UnixNano()
+	n.send(msg, addr)
+}
+
+// Returns true the first time a command id is seen, forgetting ids
+// once they are too old to be repeated
+func (n *Node) first(id string) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	now := time.Now()
+	for k, t := range n.seen {
+		if now.Sub(t) > time.Minute {
+			delete(n.seen, k)
+		}
+	}
+	if _, ok := n.seen[id]; ok {
+		return false
+	}
+	n.seen[id] = now
+	return true
+}
+
+// Waits until a command is due on the local clock and carries it out,
+// early by the output latency so it is heard at the scheduled time
+func (n *Node) schedule(msg message) {
+	at := time.Unix(0, msg.At)
+	n.closeWg.Add(1)
+	go func() {
+		defer n.closeWg.Done()
+		if msg.Type == msgPlay {
+			// Preload so the track starts on time, off the read loop so
+			// sync replies and other commands are not held up
+			if _, err := player.LoadTrack(loadTrackConfig(msg)); err != nil {
+				logger.WithError(err).Error("multi-room track load error")
+				return
+			}
+		}
+		select {
+		case <-n.closeC:
+			return
+		case <-time.After(time.Until(n.clock.Local(at).Add(-audio.Latency()))):
+		}
+		n.do(msg, at)
+	}()
+}
+
+// Carries out a command scheduled at a shared time
+func (n *Node) do(msg message, at time.Time) {
+	logger.WithFields(logger.F{
+		"type": msg.Type,
+		"late": n.clock.Now().Sub(at),
+	}).Debug("multi-room command")
+	switch msg.Type {
+	case msgPlay:
+		n.drift.Start(at)
+		if err := player.Play(loadTrackConfig(msg)); err != nil {
+			logger.WithError(err).Error("multi-room play error")
+		}
+	case msgPause:
+		n.drift.Pause(at)
+		player.Pause()
+	case msgResume:
+		n.drift.Resume(at)
+		player.Resume()
+	case msgStop:
+		n.drift.Stop()
+		player.Stop()
+	}
+}
+
+// Returns the track configuration from a play command
+func loadTrackConfig(msg message) player.LoadTrackConfig {
+	return player.LoadTrackConfig{
+		ProviderName:    msg.ProviderName,
+		ProviderTrackID: msg.ProviderTrackID,
+		PlaylistID:      msg.PlaylistID,
+		UserID:          msg.UserID,
+	}
+}
+
+// Closes the global node
+func Close() error { return node.Close() }
+
+// Stops syncing and drops any scheduled commands
+func (n *Node) Close() error {
+	if n.conn == nil {
+		return nil
+	}
+	close(n.closeC)
+	err := n.conn.Close()
+	n.closeWg.Wait()
+	return err
+}
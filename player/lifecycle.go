@@ -0,0 +1,71 @@
+package player
+
+import (
+	"time"
+
+	"player/logger"
+)
+
+// Track lifecycle states
+type State string
+
+const (
+	Loading   State = "loading"   // The track stream is being requested
+	Buffering State = "buffering" // Waiting on the track stream
+	Playing   State = "playing"   // Audio is flowing
+	Paused    State = "paused"    // Paused by a client
+	Resumed   State = "resumed"   // Resumed by a client
+	Finished  State = "finished"  // Played to the end
+	Stopped   State = "stopped"   // Stopped before the end
+	Failed    State = "failed"    // Could not be loaded or played
+)
+
+// Reasons a track finished or was stopped
+const (
+	ReasonEnd     = "end"     // Played to the end of the stream
+	ReasonSilence = "silence" // Ended early on trailing silence
+	ReasonStop    = "stop"    // Stopped by a client
+	ReasonClose   = "close"   // The player was closed
+)
+
+// A change in the lifecycle of a track
+type Change struct {
+	State      State
+	PlaylistID string
+	UserID     string
+	Reason     string        // Why the track ended, terminal states only
+	Played     time.Duration // How much of the track was played, terminal states only
+	Err        error         // Why the track failed, failed state only
+}
+
+// Returns true if the change ends the track
+func (c Change) Terminal() bool {
+	return c.State == Finished || c.State == Stopped || c.State == Failed
+}
+
+// Constructs a change of state for a track
+func newChange(state State, track *Track) Change {
+	return Change{
+		State:      state,
+		PlaylistID: track.PlaylistID,
+		UserID:     track.UserID,
+	}
+}
+
+// Track lifecycle changes, in the order they happened
+func Lifecycle() <-chan Change { return player.Lifecycle() }
+func (p *Player) Lifecycle() <-chan Change {
+	return (<-chan Change)(p.lifecycleC)
+}
+
+// Sends a lifecycle change, dropped if the player is closing
+func (p *Player) change(c Change) {
+	logger.WithFields(logger.F{
+		"state":      c.State,
+		"playlistID": c.PlaylistID,
+	}).Debug("track lifecycle change")
+	select {
+	case p.lifecycleC <- c:
+	case <-p.closeC:
+	}
+}
@@ -17,8 +17,33 @@ var (
 	ErrStop            = errors.New("stop playing")
 	ErrClose           = errors.New("close player")
 	ErrUnknownProvider = errors.New("unknown provider")
+	ErrStreamFailed    = errors.New("provider stream failed")
+	ErrDecodeFailed    = errors.New("decoding failed")
+	ErrOutputFailed    = errors.New("audio output failed")
 )
 
+// An error loading or playing a track
+type PlayError struct {
+	PlaylistID string
+	Reason     error // ErrStreamFailed, ErrDecodeFailed or ErrOutputFailed
+	Err        error // The underlying error
+}
+
+func (e *PlayError) Error() string {
+	return e.Reason.Error() + ": " + e.Err.Error()
+}
+
+// Classifies an error which ended playback of a track early
+func playError(track *Track, err error) *PlayError {
+	reason := ErrDecodeFailed
+	if ie, ok := err.(*audio.InputError); ok && ie.Write {
+		reason = ErrOutputFailed
+	} else if serr := track.Err(); serr != nil {
+		reason, err = ErrStreamFailed, serr
+	}
+	return &PlayError{track.PlaylistID, reason, err}
+}
+
 // Package initalisation
 func init() {
 	player = New()
@@ -67,6 +92,15 @@ type LoadTrackConfig struct {
 	ProviderName    string
 	ProviderTrackID string
 	PlaylistID      string
+	UserID          string
+}
+
+// Configuration to pass to player announce method, either a
+// provider track or a local sound file
+type AnnounceConfig struct {
+	ProviderName    string
+	ProviderTrackID string
+	File            string
 }
 
 // Audio Player
@@ -79,14 +113,21 @@ type Player struct {
 	paused    bool
 	pauseLock *sync.Mutex
 	pauseC    chan bool
-	pausedC   chan bool
 	resumeC   chan bool
 	// Playing
-	playing  bool
-	playingC chan bool
+	playing bool
+	current *Track
 	// Stopped
-	stopC    chan bool
-	stoppedC chan bool
+	stopC chan bool
+	// Track lifecycle changes
+	lifecycleC chan Change
+	// Dead air
+	deadAirC chan *Track
+	// Playback errors
+	errorsC chan error
+	// Announcements, played one at a time
+	announceLock *sync.Mutex
+	announceWg   *sync.WaitGroup
 	// Close orchestration
 	playWg *sync.WaitGroup
 	closeC chan bool
@@ -97,15 +138,16 @@ func Close() error { return player.Close() }
 func (p *Player) Close() error {
 	logger.Debug("close player")
 	defer logger.Info("closed player")
-	close(p.closeC) // Close the close channel
-	p.playWg.Wait() // Wait for play routines to exit
+	close(p.closeC)     // Close the close channel
+	p.playWg.Wait()     // Wait for play routines to exit
+	p.announceWg.Wait() // Wait for announcements to exit
 	return nil
 }
 
 // Pause the player
 func Pause() bool { return player.Pause() }
 func (p *Player) Pause() bool {
-	if !p.paused && p.playing {
+	if !p.paused && p.IsPlaying() {
 		logger.Debug("player not paused and playing, resume")
 		p.pauseC <- true
 		return true
@@ -113,15 +155,10 @@ func (p *Player) Pause() bool {
 	return false
 }
 
-func Paused() <-chan bool { return player.Paused() }
-func (p *Player) Paused() <-chan bool {
-	return (<-chan bool)(p.pausedC)
-}
-
 // Resume the player
 func Resume() bool { return player.Resume() }
 func (p *Player) Resume() bool {
-	if p.paused && p.playing {
+	if p.paused && p.IsPlaying() {
 		logger.Debug("player paused and playing, resume")
 		p.resumeC <- true
 		return true
@@ -132,7 +169,7 @@ func (p *Player) Resume() bool {
 // Stops playing the current playing track if playing
 func Stop() bool { return player.Stop() }
 func (p *Player) Stop() bool {
-	if p.playing {
+	if p.IsPlaying() {
 		p.stopC <- true
 		p.playWg.Wait() // Wait for play routines to exit before returning
 		return true
@@ -140,9 +177,26 @@ func (p *Player) Stop() bool {
 	return false
 }
 
-func Stopped() <-chan bool { return player.Stopped() }
-func (p *Player) Stopped() <-chan bool {
-	return (<-chan bool)(p.stoppedC)
+// Errors which ended playback, reported here as there is no caller to
+// return them to
+func Errors() <-chan error { return player.Errors() }
+func (p *Player) Errors() <-chan error {
+	return (<-chan error)(p.errorsC)
+}
+
+// Sends a playback error without blocking if nothing is listening
+func (p *Player) fail(err error) {
+	logger.WithError(err).Error("track playback failed")
+	select {
+	case p.errorsC <- err:
+	default:
+	}
+}
+
+// Send dead air signal with the track that has gone silent
+func DeadAir() <-chan *Track { return player.DeadAir() }
+func (p *Player) DeadAir() <-chan *Track {
+	return (<-chan *Track)(p.deadAirC)
 }
 
 // Returns the player paused state
@@ -151,9 +205,19 @@ func (p *Player) IsPaused() bool {
 	return p.paused
 }
 
+// Returns the currently playing track, nil if not playing
+func Current() *Track { return player.Current() }
+func (p *Player) Current() *Track {
+	p.tracksLock.Lock()
+	defer p.tracksLock.Unlock()
+	return p.current
+}
+
 // Returns the player playing state
 func IsPlaying() bool { return player.IsPlaying() }
 func (p *Player) IsPlaying() bool {
+	p.tracksLock.Lock()
+	defer p.tracksLock.Unlock()
 	return p.playing
 }
 
@@ -164,12 +228,17 @@ func (p *Player) LoadTrack(c LoadTrackConfig) (*Track, error) {
 	track = p.Tracks.Get(c.PlaylistID)
 	if track == nil {
 		provider := p.Providers.Get(c.ProviderName)
-		if p == nil {
+		if provider == nil {
 			return nil, ErrUnknownProvider
 		}
 		track = NewTrack(c.PlaylistID, c.ProviderTrackID, provider)
+		track.UserID = c.UserID
+		p.change(newChange(Loading, track))
 		if err := track.Load(); err != nil {
-			return nil, err
+			failed := newChange(Failed, track)
+			failed.Err = &PlayError{c.PlaylistID, ErrStreamFailed, err}
+			p.change(failed)
+			return nil, failed.Err
 		}
 		// Add track to player loaded tracks
 		p.tracksLock.Lock()
@@ -183,7 +252,7 @@ func (p *Player) LoadTrack(c LoadTrackConfig) (*Track, error) {
 func Play(c LoadTrackConfig) error { return player.Play(c) }
 func (p *Player) Play(c LoadTrackConfig) error {
 	// Are we playing, if we are then we can't play something else ;)
-	if p.playing {
+	if p.IsPlaying() {
 		return ErrPlaying
 	}
 	// Load the track
@@ -191,68 +260,162 @@ func (p *Player) Play(c LoadTrackConfig) error {
 	if err != nil {
 		return err
 	}
-	// Fire play goroutine
-	go p.play(track)
-	// Fire playing signal
-	p.playingC <- true
-	// Remove the track from the track store
+	// Set the current track before playback starts, removing it from
+	// the track store
 	p.tracksLock.Lock()
+	if p.playing {
+		// Started playing while loading, the loaded track will not play
+		p.Tracks.Del(c.PlaylistID)
+		p.tracksLock.Unlock()
+		track.Close()
+		failed := newChange(Failed, track)
+		failed.Err = &PlayError{c.PlaylistID, ErrPlaying, ErrPlaying}
+		p.change(failed)
+		return failed.Err
+	}
+	p.current = track
+	p.playing = true
 	p.Tracks.Del(c.PlaylistID)
 	p.tracksLock.Unlock()
+	// Fire play goroutine
+	p.playWg.Add(1)
+	go p.play(track)
 	return nil
 }
 
-// Send playing signal
-func Playing() <-chan bool { return player.Playing() }
-func (p *Player) Playing() <-chan bool {
-	return (<-chan bool)(p.playingC)
-}
-
 // Plays a track, handling pause / resume / stop events
-func (p *Player) play(track io.ReadCloser) error {
+func (p *Player) play(track *Track) error {
 	logger.Debug("start track playback")
 	defer logger.Debug("exit track playback")
 	// Close orchestration
-	p.playWg.Add(1)
 	defer p.playWg.Done()
-	// Send stopped event
-	defer func(p *Player) { p.stoppedC <- true }(p)
-	// Set state
-	p.playing = true
-	defer func(p *Player) { p.playing = false }(p) // Reset player playing statr
-	defer func(p *Player) { p.paused = false }(p)  // Reset player pause state
-	defer track.Close()                            // Close the track
+	// Signal how the track ended once playback has been cleaned up
+	end := newChange(Stopped, track)
+	end.Reason = ReasonClose
+	defer func() { p.change(end) }()
+	// Reset player state, the current track and playing state together
+	// so a new track is never cleared by the previous one
+	defer func(p *Player) {
+		p.tracksLock.Lock()
+		p.current = nil
+		p.playing = false
+		p.tracksLock.Unlock()
+	}(p)
+	defer func(p *Player) { p.paused = false }(p) // Reset player pause state
+	defer track.Close()                           // Close the track
 	// Get audio output
-	output, err := audio.Get()
+	output, err := audio.Main()
 	if err != nil {
+		end.State, end.Reason = Failed, ""
+		end.Err = &PlayError{track.PlaylistID, ErrOutputFailed, err}
+		p.fail(end.Err)
 		return err
 	}
 	// Load cassette
 	input := audio.NewInput(track, output)
 	go input.Play() // Start playing the input
-	defer input.Close()
+	defer func() {
+		input.Close()
+		end.Played = input.Played()
+	}()
+	buffering := true
+	p.change(newChange(Buffering, track))
 	for {
 		select {
 		case <-input.End():
+			end.State, end.Reason = Finished, ReasonEnd
+			if input.Trailing() {
+				end.Reason = ReasonSilence
+			}
+			if err := input.Err(); err != nil {
+				end.Err = playError(track, err)
+			} else if err := track.Err(); err != nil {
+				// Whatever was buffered played but the rest is missing
+				end.Err = &PlayError{track.PlaylistID, ErrStreamFailed, err}
+			}
+			if end.Err != nil {
+				end.State, end.Reason = Failed, ""
+				p.fail(end.Err)
+			}
 			return nil
+		case b := <-input.Buffering():
+			if b == buffering {
+				continue
+			}
+			buffering = b
+			if b {
+				p.change(newChange(Buffering, track))
+			} else {
+				p.change(newChange(Playing, track))
+			}
+		case <-input.DeadAir():
+			select {
+			case p.deadAirC <- track:
+			default:
+			}
 		case <-p.pauseC:
 			p.paused = true
-			p.pausedC <- true
+			p.change(newChange(Paused, track))
 			input.Stop()
 		case <-p.resumeC:
 			p.paused = false
-			p.playingC <- true
+			p.change(newChange(Resumed, track))
 			input.Resume()
 		case <-p.stopC:
+			end.Reason = ReasonStop
 			return nil
 		case <-p.closeC:
 			logger.Debug("close player")
 			return nil
 		}
 	}
+}
+
+// Play an announcement over the top of the current track, the track
+// is ducked while the announcement plays but its state is untouched
+func Announce(c AnnounceConfig) error { return player.Announce(c) }
+func (p *Player) Announce(c AnnounceConfig) error {
+	var stream io.ReadCloser
+	var err error
+	if c.File != "" {
+		stream, err = audio.OpenSound(c.File)
+	} else {
+		provider := p.Providers.Get(c.ProviderName)
+		if provider == nil {
+			return ErrUnknownProvider
+		}
+		stream, err = provider.Stream(c.ProviderTrackID)
+	}
+	if err != nil {
+		return err
+	}
+	output, err := audio.Overlay()
+	if err != nil {
+		stream.Close()
+		return err
+	}
+	p.announceWg.Add(1)
+	go p.announce(stream, output)
 	return nil
 }
 
+// Plays an announcement stream to the overlay output
+func (p *Player) announce(stream io.ReadCloser, output audio.Writer) {
+	defer p.announceWg.Done()
+	defer stream.Close()
+	p.announceLock.Lock()
+	defer p.announceLock.Unlock()
+	logger.Debug("start announcement playback")
+	defer logger.Debug("exit announcement playback")
+	input := audio.NewOverlayInput(stream, output)
+	go input.Play()
+	defer input.Close()
+	select {
+	case <-input.End():
+	case <-p.closeC:
+	}
+}
+
 // Consturcts a new Player with the given steamers
 func New() *Player {
 	player := &Player{
@@ -262,15 +425,18 @@ func New() *Player {
 		tracksLock: &sync.Mutex{},
 		Tracks:     make(Tracks),
 		// Orchestration channels
-		pauseLock: &sync.Mutex{},
-		stopC:     make(chan bool, 1),
-		stoppedC:  make(chan bool, 1),
-		pauseC:    make(chan bool, 1),
-		pausedC:   make(chan bool, 1),
-		resumeC:   make(chan bool, 1),
-		playingC:  make(chan bool, 1),
-		playWg:    &sync.WaitGroup{},
-		closeC:    make(chan bool, 1),
+		pauseLock:  &sync.Mutex{},
+		stopC:      make(chan bool, 1),
+		lifecycleC: make(chan Change, 16),
+		deadAirC:   make(chan *Track, 1),
+		errorsC:    make(chan error, 8),
+		pauseC:     make(chan bool, 1),
+		resumeC:    make(chan bool, 1),
+		playWg:     &sync.WaitGroup{},
+		closeC:     make(chan bool, 1),
+		// Announcements
+		announceLock: &sync.Mutex{},
+		announceWg:   &sync.WaitGroup{},
 	}
 	return player
 }
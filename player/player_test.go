@@ -0,0 +1,113 @@
+package player
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+
+	"player/audio"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// Streams a short tone, or fails to
+type testProvider struct {
+	samples int
+	err     error
+}
+
+func (p testProvider) Name() string { return "test" }
+func (p testProvider) Stream(track string) (io.ReadCloser, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	pcm := make([]int16, p.samples)
+	for i := range pcm {
+		pcm[i] = int16(math.Sin(float64(i/audio.CHANNELS)*0.05) * 8000)
+	}
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.LittleEndian, pcm)
+	return ioutil.NopCloser(buf), nil
+}
+
+// Collects lifecycle changes until a terminal change
+func changes(t *testing.T, p *Player) []Change {
+	var changes []Change
+	for {
+		select {
+		case c := <-p.Lifecycle():
+			changes = append(changes, c)
+			if c.Terminal() {
+				return changes
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out waiting for lifecycle changes")
+			return nil
+		}
+	}
+}
+
+func TestPlayerLifecycle(t *testing.T) {
+	viper.Set("audio.backend", "null")
+	viper.Set("audio.fallback", []string{})
+	if !assert.NoError(t, audio.Open()) {
+		return
+	}
+	defer audio.Close()
+	samples := audio.FRAMES_PER_BUFFER * 20
+	tt := []struct {
+		tname    string
+		provider testProvider
+		states   []State
+		reason   string
+		played   time.Duration
+	}{
+		{
+			"finished",
+			testProvider{samples: samples},
+			[]State{Loading, Buffering, Playing, Finished},
+			ReasonEnd,
+			time.Duration(samples/audio.CHANNELS) * time.Second / audio.SAMPLE_RATE,
+		},
+		{
+			"failed",
+			testProvider{err: errors.New("not found")},
+			[]State{Loading, Failed},
+			"",
+			0,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			p := New()
+			defer p.Close()
+			p.Providers.Add(tc.provider)
+			err := p.Play(LoadTrackConfig{
+				ProviderName: "test",
+				PlaylistID:   "abc",
+				UserID:       "user",
+			})
+			if tc.provider.err != nil {
+				assert.IsType(t, &PlayError{}, err)
+			}
+			var states []State
+			changes := changes(t, p)
+			for _, c := range changes {
+				states = append(states, c.State)
+				assert.Equal(t, "abc", c.PlaylistID)
+				assert.Equal(t, "user", c.UserID)
+			}
+			assert.Equal(t, tc.states, states)
+			end := changes[len(changes)-1]
+			assert.Equal(t, tc.reason, end.Reason)
+			// Leading silence is skipped
+			assert.InDelta(t, float64(tc.played), float64(end.Played), float64(time.Millisecond))
+		})
+	}
+}
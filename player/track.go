@@ -1,6 +1,22 @@
 package player
 
-import "io"
+import (
+	"io"
+	"time"
+
+	"player/audio"
+	"player/tags"
+)
+
+// Implemented by provider streams that can read tags from their audio
+type Tagger interface {
+	Tags(timeout time.Duration) *tags.Tags
+}
+
+// Implemented by provider streams that can report why buffering failed
+type Failer interface {
+	Err() error
+}
 
 // A store of tracks to play in any order
 type Tracks map[string]*Track
@@ -30,6 +46,7 @@ type Track struct {
 	PlaylistID string   // Unique track id
 	ProviderID string   // Providers track id
 	Provider   Provider // Provider of the track
+	UserID     string   // User who queued the track
 	// Unexpoted Fields
 	stream io.ReadCloser // Track audio stream
 }
@@ -42,6 +59,33 @@ func (t *Track) Read(dst []byte) (int, error) {
 	return t.stream.Read(dst)
 }
 
+// Returns the tags read from the track stream, waiting up to timeout for
+// them to be read, nil if the provider stream does not support tags
+func (t *Track) Tags(timeout time.Duration) *tags.Tags {
+	if tagger, ok := t.stream.(Tagger); ok {
+		return tagger.Tags(timeout)
+	}
+	return nil
+}
+
+// Returns why buffering the track stream failed, nil if it has not or
+// the provider stream does not report failures
+func (t *Track) Err() error {
+	if failer, ok := t.stream.(Failer); ok {
+		return failer.Err()
+	}
+	return nil
+}
+
+// Returns the bytes left to read from the track stream and true once it
+// has been fully buffered, false if the provider stream cannot tell
+func (t *Track) Remaining() (int, bool) {
+	if r, ok := t.stream.(audio.Remainer); ok {
+		return r.Remaining()
+	}
+	return 0, false
+}
+
 // Close the track closes the tracks buffer
 func (t *Track) Close() error {
 	if t.stream != nil {
@@ -4,8 +4,10 @@ package googlemusic
 
 import (
 	"io"
+	"time"
 
 	"player/buffer"
+	"player/tags"
 
 	"github.com/korandiz/mpa"
 	"github.com/krak3n/gmusic"
@@ -24,6 +26,16 @@ func (gms *GoogleMusicStream) Close() error {
 	return gms.buffer.Close()
 }
 
+// Returns why buffering the stream failed, if it has
+func (gms *GoogleMusicStream) Err() error {
+	return gms.buffer.Err()
+}
+
+// Returns the tags of the stream
+func (gms *GoogleMusicStream) Tags(timeout time.Duration) *tags.Tags {
+	return gms.buffer.Tags(timeout)
+}
+
 // Login Interface
 type LoginHandler interface {
 	Login(username, password string) (*gmusic.GMusic, error)
@@ -5,8 +5,10 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 
 	"player/buffer"
+	"player/tags"
 
 	"github.com/korandiz/mpa"
 )
@@ -24,6 +26,16 @@ func (scs *SoundCloudStream) Close() error {
 	return scs.buffer.Close()
 }
 
+// Returns why buffering the stream failed, if it has
+func (scs *SoundCloudStream) Err() error {
+	return scs.buffer.Err()
+}
+
+// Returns the tags of the stream
+func (scs *SoundCloudStream) Tags(timeout time.Duration) *tags.Tags {
+	return scs.buffer.Tags(timeout)
+}
+
 // Soundcloud Player
 type SoundCloud struct {
 	// Exported Fields
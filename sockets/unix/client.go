@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"net"
 	"sync"
+	"time"
 
 	"player/event"
 	"player/logger"
@@ -61,6 +62,12 @@ func (c *Client) Write(b []byte) (int, error) {
 	return c.conn.Write(b)
 }
 
+// Sets the deadline for writes to the connection, the event hub bounds
+// each write so a wedged client is disconnected
+func (c *Client) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
 // Close the Client, closing the connection
 func (c *Client) Close() error {
 	event.Del(c) // Delete the client from the event hub
@@ -3,12 +3,14 @@ package unix
 import "github.com/spf13/viper"
 
 const (
-	vAddress = "unixsocket.address"
+	vAddress       = "unixsocket.address"
+	vLevelsAddress = "unixsocket.levels_address"
 )
 
 func init() {
-	viper.BindEnv(vAddress)
+	viper.BindEnv(vAddress, vLevelsAddress)
 	viper.SetDefault(vAddress, "/tmp/sfmplayer.sock")
+	viper.SetDefault(vLevelsAddress, "/tmp/sfmplayer.levels.sock")
 }
 
 type Configurer interface {
@@ -21,6 +23,11 @@ func (c Config) Address() string {
 	return viper.GetString(vAddress)
 }
 
+// Address of the audio levels publisher socket
+func (c Config) LevelsAddress() string {
+	return viper.GetString(vLevelsAddress)
+}
+
 func NewConfig() Config {
 	return Config{}
 }
@@ -0,0 +1,162 @@
+package unix
+
+import (
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"player/logger"
+)
+
+const (
+	publisherQueue   = 16                   // Writes buffered per client, further writes are dropped
+	publisherTimeout = time.Second          // Time allowed for a client write before it is disconnected
+	acceptBackoffMax = time.Second          // Longest wait before retrying a failed accept
+	acceptBackoff    = time.Millisecond * 5 // First wait before retrying a failed accept
+)
+
+// A write only unix socket server, every write is sent to all
+// connected clients. Used for high rate data such as audio levels
+// which would otherwise flood the event hub clients
+type Publisher struct {
+	// Exported Fields
+	Address string
+	// Unexported Fields
+	listener  net.Listener
+	connsLock *sync.Mutex
+	conns     map[net.Conn]chan []byte // Connections to their buffered writes
+	wg        *sync.WaitGroup
+	closeC    chan bool
+}
+
+// Opens the socket and accepts new connections to publish to until closed
+func (p *Publisher) Listen() error {
+	if _, err := os.Stat(p.Address); err == nil {
+		if err := os.Remove(p.Address); err != nil {
+			return err
+		}
+	}
+	l, err := net.Listen("unix", p.Address)
+	if err != nil {
+		return err
+	}
+	p.listener = l
+	p.wg.Add(1)
+	go p.accept(l)
+	return nil
+}
+
+// Accepts new connections until the listener is closed
+func (p *Publisher) accept(l net.Listener) {
+	logger.WithField("address", p.Address).Debug("start socket publisher listen")
+	defer logger.Debug("exit socket publisher listen")
+	defer p.wg.Done()
+	backoff := acceptBackoff
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-p.closeC:
+				return
+			default:
+				logger.WithError(err).Error("failed to accept unix connection")
+			}
+			// Persistent errors are retried without spinning
+			select {
+			case <-p.closeC:
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > acceptBackoffMax {
+				backoff = acceptBackoffMax
+			}
+			continue
+		}
+		backoff = acceptBackoff
+		queueC := make(chan []byte, publisherQueue)
+		p.connsLock.Lock()
+		p.conns[conn] = queueC
+		p.wg.Add(1)
+		go p.send(conn, queueC)
+		p.connsLock.Unlock()
+		logger.Debug("unix socket publisher client connected")
+	}
+}
+
+// Writes buffered data to a client until it fails a write or the
+// publisher is closed, the client is then disconnected
+func (p *Publisher) send(conn net.Conn, queueC chan []byte) {
+	defer p.wg.Done()
+	defer p.disconnect(conn)
+	for {
+		select {
+		case <-p.closeC:
+			return
+		case b := <-queueC:
+			conn.SetWriteDeadline(time.Now().Add(publisherTimeout))
+			if _, err := conn.Write(b); err != nil {
+				logger.WithError(err).Debug("unix socket publisher client gone")
+				return
+			}
+		}
+	}
+}
+
+// Closes a client connection and stops publishing to it
+func (p *Publisher) disconnect(conn net.Conn) {
+	p.connsLock.Lock()
+	defer p.connsLock.Unlock()
+	conn.Close()
+	delete(p.conns, conn)
+}
+
+// Buffers data for all connected clients without waiting on them, data
+// is dropped for clients whose buffer is full
+func (p *Publisher) Write(b []byte) (int, error) {
+	n := len(b)
+	line := make([]byte, n, n+1)
+	copy(line, b)
+	if n == 0 || line[n-1] != '\n' {
+		line = append(line, '\n')
+	}
+	p.connsLock.Lock()
+	defer p.connsLock.Unlock()
+	for _, queueC := range p.conns {
+		select {
+		case queueC <- line:
+		default:
+		}
+	}
+	return n, nil
+}
+
+// Closes the listener and all client connections
+func (p *Publisher) Close() error {
+	logger.Debug("close socket publisher")
+	defer logger.Info("closed socket publisher")
+	defer os.Remove(p.Address)
+	close(p.closeC)
+	if p.listener != nil {
+		p.listener.Close()
+	}
+	p.connsLock.Lock()
+	for conn := range p.conns {
+		conn.Close()
+		delete(p.conns, conn)
+	}
+	p.connsLock.Unlock()
+	p.wg.Wait()
+	return nil
+}
+
+// Constructs a new Publisher on the given socket address
+func NewPublisher(address string) *Publisher {
+	return &Publisher{
+		Address:   address,
+		connsLock: &sync.Mutex{},
+		conns:     make(map[net.Conn]chan []byte),
+		wg:        &sync.WaitGroup{},
+		closeC:    make(chan bool),
+	}
+}
@@ -0,0 +1,72 @@
+package unix
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublisherWedgedClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "publisher")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	p := NewPublisher(filepath.Join(dir, "levels.sock"))
+	assert.NoError(t, p.Listen())
+	// Never reads what is published to it
+	wedged, err := net.Dial("unix", p.Address)
+	assert.NoError(t, err)
+	defer wedged.Close()
+	reader, err := net.Dial("unix", p.Address)
+	assert.NoError(t, err)
+	defer reader.Close()
+	for {
+		p.connsLock.Lock()
+		n := len(p.conns)
+		p.connsLock.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	lines := make(chan string, 1)
+	go func() {
+		r := bufio.NewReader(reader)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			select {
+			case lines <- line:
+			default:
+			}
+		}
+	}()
+	// Far more than the wedged client's socket and buffer can hold
+	payload := make([]byte, 64*1024)
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			p.Write(payload)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("write blocked on a wedged client")
+	}
+	select {
+	case line := <-lines:
+		assert.Len(t, line, len(payload)+1)
+	case <-time.After(time.Second):
+		t.Fatal("reading client not written to")
+	}
+	assert.NoError(t, p.Close())
+}
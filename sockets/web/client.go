@@ -16,10 +16,12 @@ package web
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -58,9 +60,18 @@ type Client struct {
 	Config Configurer
 	// Unexported Fields
 	id string
-	// Connection & state
+	// Connection & state, guarded by the lock which is never held while
+	// writing so writes from the event hub do not wait on the connection
+	lock      *sync.Mutex
 	conn      ReadWriteCloser
-	connected bool
+	connected bool   // Connected and resumed, events are queued until then
+	added     bool   // Added to the event hub
+	seq       uint64 // Sequence number of the last event written
+	queue     *queue // Events written while disconnected
+	missed    bool   // Events dropped by the event hub, replayed from the journal
+	deadline  time.Time
+	// Serialises writes to the connection
+	writeLock *sync.Mutex
 	// Received messages
 	messageC chan message
 	// Orchestraion
@@ -83,17 +94,136 @@ func (c *Client) headers() http.Header {
 	encoded := base64.StdEncoding.EncodeToString([]byte(plain))
 	headers.Add("Authorization", fmt.Sprintf("Basic %s", encoded))
 	// Topics we want to subscribe too
-	// TODO: live in config?
-	topics := []string{
-		"player:play",
-		"player:stop",
-		"player:pause",
-		"player:resume",
-	}
-	headers.Add("Topics", strings.Join(topics, ","))
+	headers.Add("Topics", strings.Join(c.Config.Topics(), ","))
+	// Sequence number of the last event written
+	c.lock.Lock()
+	seq := c.seq
+	c.lock.Unlock()
+	if seq > 0 {
+		headers.Add("Since", strconv.FormatUint(seq, 10))
+	}
 	return headers
 }
 
+// Returns the sequence number the server replied with in a Since
+// header naming the last event it received, 0 if there was none
+func (c *Client) since(rsp *http.Response) uint64 {
+	if rsp == nil {
+		return 0
+	}
+	v := rsp.Header.Get("Since")
+	if v == "" {
+		return 0
+	}
+	seq, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		logger.WithError(err).Warn("invalid websocket since header")
+		return 0
+	}
+	return seq
+}
+
+// Writes the events missed while disconnected to a new connection before
+// live events resume, replaying them from the event journal after the
+// sequence number the server asked for or, if events were dropped, after
+// the last event written, then flushing the queue. Events written
+// meanwhile are queued behind them until the queue is empty.
+func (c *Client) resume(conn ReadWriteCloser, since uint64) error {
+	for {
+		c.lock.Lock()
+		items, lost := c.queue.take()
+		if since == 0 && (lost || c.missed) {
+			since = c.seq
+		}
+		c.missed = false
+		if since == 0 && len(items) == 0 {
+			c.conn = conn
+			c.connected = true
+			c.lock.Unlock()
+			return nil
+		}
+		c.lock.Unlock()
+		if err := c.replay(conn, since); err != nil {
+			c.requeue(items, true)
+			return err
+		}
+		for i, m := range items {
+			// Writes are bounded by the retry interval, not the hub deadline
+			if err := c.send(conn, m.b, m.seq, time.Now().Add(c.Config.Retry())); err != nil {
+				c.requeue(items[i:], false)
+				return err
+			}
+		}
+		logger.WithFields(logger.F{
+			"since":  since,
+			"queued": len(items),
+			"lost":   lost,
+		}).Debug("resumed websocket events")
+		since = 0
+	}
+}
+
+// Writes the journaled events after a sequence number to a connection,
+// nothing is written if the sequence number is 0
+func (c *Client) replay(conn ReadWriteCloser, since uint64) error {
+	if since == 0 {
+		return nil
+	}
+	events, complete := event.Since(since)
+	if !complete {
+		logger.WithField("since", since).Warn("events dropped from the journal before replay")
+	}
+	for _, e := range events {
+		b, err := json.Marshal(&e)
+		if err != nil {
+			return err
+		}
+		if err := c.send(conn, b, e.Seq, time.Now().Add(c.Config.Retry())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Puts events back at the front of the queue after a failed resume,
+// ahead of events queued since, missed if the journal replay failed
+func (c *Client) requeue(items []queued, missed bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	queued, _ := c.queue.take()
+	for _, m := range append(items, queued...) {
+		c.queue.push(m)
+	}
+	c.missed = c.missed || missed
+}
+
+// Writes an event to a connection unless a later event has already been
+// written, recording its sequence number
+func (c *Client) send(conn ReadWriteCloser, b []byte, seq uint64, deadline time.Time) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	c.lock.Lock()
+	written := seq > 0 && seq <= c.seq
+	c.lock.Unlock()
+	if written {
+		return nil // Already replayed from the journal
+	}
+	if d, ok := conn.(event.Deadliner); ok {
+		if err := d.SetWriteDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, b); err != nil {
+		return err
+	}
+	if seq > 0 {
+		c.lock.Lock()
+		c.seq = seq
+		c.lock.Unlock()
+	}
+	return nil
+}
+
 // Connect to server
 func (c *Client) connect() {
 	logger.Debug("start websocket connect lopp")
@@ -108,16 +238,26 @@ func (c *Client) connect() {
 			break
 		}
 		logger.WithField("url", c.url()).Debug("connecting to websocket server")
-		conn, _, err := dialer.Dial(c.url(), c.headers())
+		conn, rsp, err := dialer.Dial(c.url(), c.headers())
 		if err != nil {
 			logger.WithError(err).Error("failed to connect to websocket server")
 			continue
 		}
 		conn.SetPingHandler(c.ping)
-		c.connected = true
-		c.conn = conn
-		event.Add(c) // Add to event hub
-		go c.read()  // Start a read routine
+		if err := c.resume(conn, c.since(rsp)); err != nil {
+			conn.Close()
+			logger.WithError(err).Error("failed to resume websocket events")
+			continue
+		}
+		c.lock.Lock()
+		added := c.added
+		c.added = true
+		c.lock.Unlock()
+		// Stays in the event hub while disconnected so events are queued
+		if !added {
+			event.Add(c)
+		}
+		go c.read(conn) // Start a read routine
 		break
 	}
 }
@@ -125,21 +265,33 @@ func (c *Client) connect() {
 // Ping handler, pongs back
 func (c *Client) ping(string) error {
 	logger.Debug("ping from websocket server")
-	return c.conn.WriteMessage(websocket.PongMessage, []byte{})
+	c.lock.Lock()
+	conn := c.conn
+	c.lock.Unlock()
+	if conn == nil {
+		return nil
+	}
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return conn.WriteMessage(websocket.PongMessage, []byte{})
 }
 
 // Reads messages from the websocket connection
-func (c *Client) read() {
+func (c *Client) read(conn ReadWriteCloser) {
 	c.wg.Add(1)
 	defer c.wg.Done()
 	logger.Debug("start websocket read loop")
 	defer logger.Debug("exit websocket read loop")
-	for c.connected {
-		typ, msg, err := c.conn.ReadMessage()
+	for {
+		typ, msg, err := conn.ReadMessage()
 		if err != nil {
-			c.connected = false
-			c.conn = nil
-			event.Del(c) // Remove from event hub
+			// Events are queued until reconnected
+			c.lock.Lock()
+			if c.conn == conn {
+				c.connected = false
+				c.conn = nil
+			}
+			c.lock.Unlock()
 			defer logger.WithError(err).Error("error reading websocket server")
 			select {
 			case <-c.closeC:
@@ -181,9 +333,18 @@ func (c *Client) Connect() {
 
 // Connected state
 func (c *Client) Connected() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	return c.connected
 }
 
+// Returns statistics of the queue of events written while disconnected
+func (c *Client) Stats() QueueStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.queue.stats()
+}
+
 // Read messages from the websocket server
 func (c *Client) Read() ([]byte, error) {
 	select {
@@ -194,16 +355,83 @@ func (c *Client) Read() ([]byte, error) {
 	}
 }
 
-// Writes messages to websocket server
+// Writes messages to websocket server, messages are queued while
+// disconnected and written on reconnect
 func (c *Client) Write(b []byte) (int, error) {
-	if c.connected && c.conn != nil {
-		if err := c.conn.WriteMessage(websocket.TextMessage, b); err != nil {
-			return 0, err
+	var e struct {
+		Topic string `json:"topic"`
+		Seq   uint64 `json:"seq"`
+	}
+	json.Unmarshal(b, &e) // Events which cannot be decoded are not sequenced
+	m := queued{e.Topic, e.Seq, b}
+	for {
+		c.lock.Lock()
+		if m.seq > 0 && m.seq <= c.seq {
+			c.lock.Unlock()
+			return len(b), nil // Already replayed from the journal
+		}
+		if !c.connected {
+			c.enqueue(m)
+			c.lock.Unlock()
+			return len(b), nil
 		}
-		return len(b), nil
+		conn, deadline := c.conn, c.deadline
+		var since uint64
+		if c.missed {
+			since, c.missed = c.seq, false
+		}
+		c.lock.Unlock()
+		err := c.replay(conn, since)
+		if err == nil {
+			err = c.send(conn, b, m.seq, deadline)
+		}
+		if err == nil {
+			return len(b), nil
+		}
+		c.lock.Lock()
+		c.missed = c.missed || since > 0
+		if c.conn == conn {
+			// Reconnect, the message is written once reconnected
+			logger.WithError(err).Error("error writing to websocket server")
+			c.connected = false
+			c.conn = nil
+			conn.Close()
+		}
+		reconnected := c.connected
+		if !reconnected {
+			c.enqueue(m)
+		}
+		c.lock.Unlock()
+		if !reconnected {
+			return len(b), nil
+		}
+	}
+}
+
+// Called by the event hub when events did not fit the client buffer,
+// they are replayed from the journal before the next event is written
+func (c *Client) Missed() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.missed = true
+}
+
+// Sets the deadline for writes to the connection, writes which miss it
+// are queued until reconnected so the event hub never waits on the server
+func (c *Client) SetWriteDeadline(t time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.deadline = t
+	return nil
+}
+
+// Queues a message, warning once the queue starts dropping messages
+func (c *Client) enqueue(m queued) {
+	dropped := c.queue.dropped
+	c.queue.push(m)
+	if dropped == 0 && c.queue.dropped > 0 {
+		logger.WithField("size", c.queue.size).Warn("websocket queue full, dropping events")
 	}
-	logger.Warn("unable to write to websocket server")
-	return 0, nil
 }
 
 // Gracefully closes the websocket connection
@@ -212,16 +440,23 @@ func (c *Client) Close() error {
 	defer logger.Info("closed websocket client")
 	// Close the closeC
 	close(c.closeC)
+	event.Del(c) // Remove from event hub
 	// Close the websocket connection
-	if c.connected && c.conn != nil {
-		err := c.conn.WriteMessage(
+	c.lock.Lock()
+	conn := c.conn
+	c.connected = false
+	c.lock.Unlock()
+	if conn != nil {
+		c.writeLock.Lock()
+		err := conn.WriteMessage(
 			websocket.CloseMessage,
 			websocket.FormatCloseMessage(
 				websocket.CloseNormalClosure, ""))
+		c.writeLock.Unlock()
 		if err != nil {
 			logger.WithError(err).Error("error closing connection")
 		}
-		if err := c.conn.Close(); err != nil {
+		if err := conn.Close(); err != nil {
 			logger.WithError(err).Error("error closing connection")
 		}
 	}
@@ -239,6 +474,10 @@ func New(c Configurer) *Client {
 		id: xid.New().String(),
 		// Read messages
 		messageC: make(chan message),
+		// State
+		lock:      &sync.Mutex{},
+		writeLock: &sync.Mutex{},
+		queue:     newQueue(c),
 		// Orechestration
 		wg:       &sync.WaitGroup{},
 		closeC:   make(chan bool, 1),
@@ -0,0 +1,105 @@
+package web
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"player/event"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Records messages written to it
+type testConn struct {
+	written []event.Event
+}
+
+func (c *testConn) ReadMessage() (int, []byte, error) { return 0, nil, nil }
+func (c *testConn) Close() error                      { return nil }
+func (c *testConn) WriteMessage(typ int, b []byte) error {
+	e := event.Event{}
+	if err := json.Unmarshal(b, &e); err != nil {
+		return err
+	}
+	c.written = append(c.written, e)
+	return nil
+}
+
+func TestClientQueue(t *testing.T) {
+	c := New(testConfig{8, PolicyCoalesce, []string{event.BufferingEvent}})
+	write := func(topic string, seq uint64) {
+		b, _ := json.Marshal(&event.Event{Topic: topic, Seq: seq})
+		_, err := c.Write(b)
+		assert.NoError(t, err)
+	}
+	// Queued while disconnected
+	write(event.BufferingEvent, 1)
+	write(event.StoppedEvent, 2)
+	write(event.BufferingEvent, 3)
+	write(event.ErrorEvent, 0)
+	assert.Equal(t, QueueStats{Size: 8, Depth: 3, Coalesced: 1}, c.Stats())
+	// Flushed in order on reconnect
+	conn := &testConn{}
+	assert.NoError(t, c.resume(conn, 0))
+	write(event.PlayingEvent, 4)
+	write(event.PlayingEvent, 4) // Already written
+	var topics []string
+	for _, e := range conn.written {
+		topics = append(topics, e.Topic)
+	}
+	assert.Equal(t, []string{
+		event.StoppedEvent,
+		event.BufferingEvent,
+		event.ErrorEvent,
+		event.PlayingEvent,
+	}, topics)
+	assert.Equal(t, 0, c.Stats().Depth)
+}
+
+// Blocks writes until the gate is closed, safe for concurrent use
+type gateConn struct {
+	testConn
+	gateC chan bool
+	lock  sync.Mutex
+}
+
+func (c *gateConn) WriteMessage(typ int, b []byte) error {
+	<-c.gateC
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.testConn.WriteMessage(typ, b)
+}
+
+func TestClientWriteDuringResume(t *testing.T) {
+	c := New(testConfig{8, PolicyOldest, nil})
+	write := func(topic string, seq uint64) {
+		b, _ := json.Marshal(&event.Event{Topic: topic, Seq: seq})
+		_, err := c.Write(b)
+		assert.NoError(t, err)
+	}
+	write(event.StoppedEvent, 1)
+	conn := &gateConn{gateC: make(chan bool)}
+	doneC := make(chan error)
+	go func() { doneC <- c.resume(conn, 0) }()
+	// Queued behind the resume rather than waiting on the connection
+	writtenC := make(chan bool)
+	go func() {
+		write(event.PlayingEvent, 2)
+		close(writtenC)
+	}()
+	select {
+	case <-writtenC:
+	case <-time.After(time.Second):
+		t.Fatal("write waited on the resume")
+	}
+	close(conn.gateC)
+	assert.NoError(t, <-doneC)
+	assert.True(t, c.Connected())
+	var topics []string
+	for _, e := range conn.written {
+		topics = append(topics, e.Topic)
+	}
+	assert.Equal(t, []string{event.StoppedEvent, event.PlayingEvent}, topics)
+}
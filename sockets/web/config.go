@@ -7,16 +7,42 @@ import (
 )
 
 const (
-	vHost     = "websocket.host"
-	vRetry    = "websocket.retry"
-	vUsername = "websocket.username"
-	vPassword = "websocket.password"
+	vHost          = "websocket.host"
+	vRetry         = "websocket.retry"
+	vUsername      = "websocket.username"
+	vPassword      = "websocket.password"
+	vTopics        = "websocket.topics"
+	vQueueSize     = "websocket.queue.size"
+	vQueuePolicy   = "websocket.queue.policy"
+	vQueueCoalesce = "websocket.queue.coalesce"
 )
 
 func init() {
 	viper.SetDefault(vHost, "localhost:8000")
 	viper.SetDefault(vRetry, "5s")
-	viper.BindEnv(vHost, vRetry, vUsername, vPassword)
+	viper.SetDefault(vTopics, []string{
+		"player:play",
+		"player:stop",
+		"player:pause",
+		"player:resume",
+		"player:announce",
+		"player:output",
+	})
+	viper.SetDefault(vQueueSize, 256)
+	viper.SetDefault(vQueuePolicy, "coalesce")
+	viper.SetDefault(vQueueCoalesce, []string{
+		"player:buffering",
+		"player:output:error",
+	})
+	viper.BindEnv(
+		vHost,
+		vRetry,
+		vUsername,
+		vPassword,
+		vTopics,
+		vQueueSize,
+		vQueuePolicy,
+		vQueueCoalesce)
 }
 
 type Configurer interface {
@@ -24,6 +50,10 @@ type Configurer interface {
 	Retry() time.Duration
 	Username() string
 	Password() string
+	Topics() []string
+	QueueSize() int
+	QueuePolicy() string
+	QueueCoalesce() []string
 }
 
 type Config struct{}
@@ -44,6 +74,27 @@ func (c Config) Password() string {
 	return viper.GetString(vPassword)
 }
 
+// Topics to subscribe to on the websocket server
+func (c Config) Topics() []string {
+	return viper.GetStringSlice(vTopics)
+}
+
+// Events queued while disconnected before events are dropped
+func (c Config) QueueSize() int {
+	return viper.GetInt(vQueueSize)
+}
+
+// Queue policy, oldest drops the oldest event when full, coalesce
+// also replaces queued events of the coalesced topics with newer ones
+func (c Config) QueuePolicy() string {
+	return viper.GetString(vQueuePolicy)
+}
+
+// Progress topics of which only the latest queued event is kept
+func (c Config) QueueCoalesce() []string {
+	return viper.GetStringSlice(vQueueCoalesce)
+}
+
 func NewConfig() Config {
 	return Config{}
 }
@@ -0,0 +1,86 @@
+package web
+
+// Queue policies
+const (
+	PolicyOldest   = "oldest"
+	PolicyCoalesce = "coalesce"
+)
+
+// Queue statistics
+type QueueStats struct {
+	Size      int    `json:"size"`      // Events the queue holds
+	Depth     int    `json:"depth"`     // Events queued
+	Dropped   uint64 `json:"dropped"`   // Events dropped because the queue was full
+	Coalesced uint64 `json:"coalesced"` // Events replaced by a newer event of the same topic
+}
+
+// A queued event
+type queued struct {
+	topic string
+	seq   uint64 // Journal sequence number, 0 if not journaled
+	b     []byte
+}
+
+// Bounded queue of events written while disconnected, not safe for
+// concurrent use
+type queue struct {
+	size      int
+	coalesce  map[string]bool // Topics coalesced, nil for the oldest policy
+	items     []queued
+	dropped   uint64
+	coalesced uint64
+	lost      bool // Events dropped since the queue was last taken
+}
+
+// Adds an event, replacing a queued event of the same topic if the
+// topic is coalesced, else dropping the oldest event if full
+func (q *queue) push(m queued) {
+	if q.coalesce[m.topic] {
+		for i, item := range q.items {
+			if item.topic == m.topic {
+				q.items = append(q.items[:i], q.items[i+1:]...)
+				q.coalesced++
+				break
+			}
+		}
+	}
+	if len(q.items) >= q.size {
+		q.items = q.items[1:]
+		q.dropped++
+		q.lost = true
+	}
+	q.items = append(q.items, m)
+}
+
+// Removes and returns the queued events, oldest first, and true if
+// events were dropped since the queue was last taken
+func (q *queue) take() ([]queued, bool) {
+	items, lost := q.items, q.lost
+	q.items, q.lost = nil, false
+	return items, lost
+}
+
+// Returns queue statistics
+func (q *queue) stats() QueueStats {
+	return QueueStats{
+		Size:      q.size,
+		Depth:     len(q.items),
+		Dropped:   q.dropped,
+		Coalesced: q.coalesced,
+	}
+}
+
+// Constructs a queue with the configured size and policy
+func newQueue(c Configurer) *queue {
+	q := &queue{size: c.QueueSize()}
+	if q.size < 1 {
+		q.size = 1
+	}
+	if c.QueuePolicy() == PolicyCoalesce {
+		q.coalesce = make(map[string]bool)
+		for _, topic := range c.QueueCoalesce() {
+			q.coalesce[topic] = true
+		}
+	}
+	return q
+}
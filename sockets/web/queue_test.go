@@ -0,0 +1,60 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testConfig struct {
+	size     int
+	policy   string
+	coalesce []string
+}
+
+func (c testConfig) Host() string            { return "" }
+func (c testConfig) Retry() time.Duration    { return 0 }
+func (c testConfig) Username() string        { return "" }
+func (c testConfig) Password() string        { return "" }
+func (c testConfig) Topics() []string        { return nil }
+func (c testConfig) QueueSize() int          { return c.size }
+func (c testConfig) QueuePolicy() string     { return c.policy }
+func (c testConfig) QueueCoalesce() []string { return c.coalesce }
+
+func TestQueue(t *testing.T) {
+	tt := []struct {
+		tname     string
+		policy    string
+		topics    []string
+		queued    []string
+		dropped   uint64
+		coalesced uint64
+	}{
+		{"fits", PolicyOldest, []string{"a", "b"}, []string{"a", "b"}, 0, 0},
+		{"oldest", PolicyOldest, []string{"a", "b", "c", "d"}, []string{"b", "c", "d"}, 1, 0},
+		{"coalesce", PolicyCoalesce, []string{"p", "a", "p"}, []string{"a", "p"}, 0, 1},
+		{"coalesce full", PolicyCoalesce, []string{"a", "b", "c", "p"}, []string{"b", "c", "p"}, 1, 0},
+		{"oldest ignores coalesce", PolicyOldest, []string{"p", "p"}, []string{"p", "p"}, 0, 0},
+	}
+	for _, tc := range tt {
+		t.Run(tc.tname, func(t *testing.T) {
+			q := newQueue(testConfig{3, tc.policy, []string{"p"}})
+			for i, topic := range tc.topics {
+				q.push(queued{topic: topic, seq: uint64(i + 1)})
+			}
+			stats := q.stats()
+			assert.Equal(t, len(tc.queued), stats.Depth)
+			assert.Equal(t, tc.dropped, stats.Dropped)
+			assert.Equal(t, tc.coalesced, stats.Coalesced)
+			items, lost := q.take()
+			var topics []string
+			for _, m := range items {
+				topics = append(topics, m.topic)
+			}
+			assert.Equal(t, tc.queued, topics)
+			assert.Equal(t, tc.dropped > 0, lost)
+			assert.Equal(t, 0, q.stats().Depth)
+		})
+	}
+}
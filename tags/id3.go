@@ -0,0 +1,185 @@
+// ID3v2.2, ID3v2.3 and ID3v2.4 tags
+
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// ID3v2 header flags
+const (
+	id3Unsynchronisation = 0x80
+	id3ExtendedHeader    = 0x40
+)
+
+// Decodes a 28 bit syncsafe integer
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// Reverses unsynchronisation, 0xff 0x00 becomes 0xff
+func unsynchronise(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		out = append(out, b[i])
+		if b[i] == 0xff && i+1 < len(b) && b[i+1] == 0x00 {
+			i++
+		}
+	}
+	return out
+}
+
+// Decodes ID3 text in the given encoding, trimming null terminators
+func id3Text(enc byte, b []byte) string {
+	var s string
+	switch enc {
+	case 1, 2: // UTF-16 with BOM, UTF-16BE
+		order := binary.ByteOrder(binary.BigEndian)
+		if len(b) >= 2 && b[0] == 0xff && b[1] == 0xfe {
+			order, b = binary.LittleEndian, b[2:]
+		} else if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+			b = b[2:]
+		}
+		u := make([]uint16, len(b)/2)
+		for i := range u {
+			u[i] = order.Uint16(b[i*2:])
+		}
+		s = string(utf16.Decode(u))
+	case 3: // UTF-8
+		s = string(b)
+	default: // ISO-8859-1
+		r := make([]rune, len(b))
+		for i, c := range b {
+			r[i] = rune(c)
+		}
+		s = string(r)
+	}
+	return strings.TrimRight(s, "\x00")
+}
+
+// Splits a null terminated string in the given encoding from the front
+// of b, returning the string and the remaining bytes
+func id3Terminated(enc byte, b []byte) (string, []byte) {
+	if enc == 1 || enc == 2 {
+		for i := 0; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				return id3Text(enc, b[:i]), b[i+2:]
+			}
+		}
+		return id3Text(enc, b), nil
+	}
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return id3Text(enc, b[:i]), b[i+1:]
+	}
+	return id3Text(enc, b), nil
+}
+
+// Parses an APIC (v2.3/v2.4) or PIC (v2.2) frame
+func id3Picture(id string, b []byte) *Picture {
+	if len(b) < 2 {
+		return nil
+	}
+	enc, b := b[0], b[1:]
+	p := &Picture{}
+	if id == "PIC" {
+		if len(b) < 4 {
+			return nil
+		}
+		p.MIME = "image/" + strings.ToLower(string(b[:3]))
+		if p.MIME == "image/jpg" {
+			p.MIME = "image/jpeg"
+		}
+		b = b[3:]
+	} else {
+		p.MIME, b = id3Terminated(0, b)
+		if len(b) < 1 {
+			return nil
+		}
+	}
+	p.Type, b = int(b[0]), b[1:]
+	_, p.Data = id3Terminated(enc, b)
+	return p
+}
+
+// Reads an ID3v2 tag
+func readID3(r io.Reader) (*Tags, error) {
+	header, err := readN(r, 10)
+	if err != nil {
+		return nil, err
+	}
+	version, flags := header[3], header[5]
+	if version < 2 || version > 4 {
+		return nil, ErrMalformed
+	}
+	body, err := readN(r, syncsafe(header[6:]))
+	if err != nil {
+		return nil, err
+	}
+	if flags&id3Unsynchronisation != 0 && version < 4 {
+		body = unsynchronise(body)
+	}
+	if flags&id3ExtendedHeader != 0 && version > 2 && len(body) >= 4 {
+		size := int(binary.BigEndian.Uint32(body))
+		if version == 3 {
+			size += 4 // v2.3 size excludes itself
+		} else {
+			size = syncsafe(body)
+		}
+		if size > len(body) {
+			return nil, ErrMalformed
+		}
+		body = body[size:]
+	}
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+	t := &Tags{}
+	for len(body) >= headerSize && body[0] != 0 {
+		id := string(body[:idSize])
+		var size int
+		var frameFlags uint16
+		switch version {
+		case 2:
+			size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(body[4:]))
+			frameFlags = binary.BigEndian.Uint16(body[8:])
+		case 4:
+			size = syncsafe(body[4:])
+			frameFlags = binary.BigEndian.Uint16(body[8:])
+		}
+		if size < 0 || size > len(body)-headerSize {
+			return nil, ErrMalformed
+		}
+		data := body[headerSize : headerSize+size]
+		body = body[headerSize+size:]
+		if version == 4 && frameFlags&0x0002 != 0 {
+			data = unsynchronise(data)
+		}
+		// Skip compressed or encrypted frames
+		if (version == 3 && frameFlags&0x00c0 != 0) || (version == 4 && frameFlags&0x000c != 0) {
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+		switch id {
+		case "TIT2", "TT2":
+			t.Title = id3Text(data[0], data[1:])
+		case "TPE1", "TP1":
+			t.Artist = id3Text(data[0], data[1:])
+		case "TALB", "TAL":
+			t.Album = id3Text(data[0], data[1:])
+		case "TRCK", "TRK":
+			t.Track = parseTrack(id3Text(data[0], data[1:]))
+		case "APIC", "PIC":
+			t.setPicture(id3Picture(id, data))
+		}
+	}
+	return t, nil
+}
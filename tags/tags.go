@@ -0,0 +1,92 @@
+// Audio tag parsing
+//
+// Reads title, artist, album, track number and embedded artwork from the
+// start of an audio stream, supports ID3v2 (mp3), FLAC and Ogg Vorbis/Opus
+// comments.
+
+package tags
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Tag parsing errors
+var (
+	ErrNoTags    = errors.New("no supported tags found")
+	ErrMalformed = errors.New("malformed tags")
+)
+
+// Largest tag block we are willing to read into memory
+const maxTagSize = 16 * 1024 * 1024
+
+// Front cover picture type, shared by ID3v2 APIC and FLAC PICTURE
+const frontCover = 3
+
+// Embedded artwork
+type Picture struct {
+	MIME string // Image mime type, e.g: image/jpeg
+	Type int    // Picture type, 3 is the front cover
+	Data []byte // Raw image data
+}
+
+// Tags read from an audio stream
+type Tags struct {
+	Title   string
+	Artist  string
+	Album   string
+	Track   int
+	Picture *Picture
+}
+
+// Sets the picture, preferring the front cover over other pictures
+func (t *Tags) setPicture(p *Picture) {
+	if p == nil || len(p.Data) == 0 {
+		return
+	}
+	if t.Picture == nil || (t.Picture.Type != frontCover && p.Type == frontCover) {
+		t.Picture = p
+	}
+}
+
+// Parses a track number which may be in the form of 3/12
+func parseTrack(s string) int {
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		s = s[:i]
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(s))
+	return n
+}
+
+// Reads tags from the start of an audio stream
+func Read(r io.Reader) (*Tags, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, ErrNoTags
+	}
+	switch {
+	case string(magic[:3]) == "ID3":
+		return readID3(br)
+	case string(magic) == "fLaC":
+		return readFLAC(br)
+	case string(magic) == "OggS":
+		return readOgg(br)
+	}
+	return nil, ErrNoTags
+}
+
+// Reads exactly n bytes, guarding against absurd sizes
+func readN(r io.Reader, n int) ([]byte, error) {
+	if n < 0 || n > maxTagSize {
+		return nil, ErrMalformed
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, ErrMalformed
+	}
+	return b, nil
+}
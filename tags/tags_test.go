@@ -0,0 +1,94 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Builds an ID3v2.3 tag from frame ids and bodies
+func id3v23(frames ...[]byte) []byte {
+	var body []byte
+	for i := 0; i < len(frames); i += 2 {
+		size := make([]byte, 4)
+		binary.BigEndian.PutUint32(size, uint32(len(frames[i+1])))
+		body = append(body, frames[i]...)
+		body = append(body, size...)
+		body = append(body, 0, 0)
+		body = append(body, frames[i+1]...)
+	}
+	n := len(body)
+	header := []byte{'I', 'D', '3', 3, 0, 0,
+		byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+	return append(header, body...)
+}
+
+// Builds a FLAC stream with a single vorbis comment block
+func flac(comments ...string) []byte {
+	var block bytes.Buffer
+	binary.Write(&block, binary.LittleEndian, uint32(0))
+	binary.Write(&block, binary.LittleEndian, uint32(len(comments)))
+	for _, c := range comments {
+		binary.Write(&block, binary.LittleEndian, uint32(len(c)))
+		block.WriteString(c)
+	}
+	n := block.Len()
+	b := []byte{'f', 'L', 'a', 'C', 0x80 | flacVorbisComment, byte(n >> 16), byte(n >> 8), byte(n)}
+	return append(b, block.Bytes()...)
+}
+
+func TestRead(t *testing.T) {
+	tt := []struct {
+		name     string
+		stream   []byte
+		expected *Tags
+		err      error
+	}{
+		{
+			"id3v2.3",
+			append(id3v23(
+				[]byte("TIT2"), []byte("\x03Song\x00"),
+				[]byte("TPE1"), []byte("\x01\xff\xfeA\x00B\x00"),
+				[]byte("TALB"), []byte("\x00Alb\xe9"),
+				[]byte("TRCK"), []byte("\x003/12"),
+				[]byte("APIC"), []byte("\x00image/png\x00\x00\x00back"),
+				[]byte("APIC"), []byte("\x00image/jpeg\x00\x03cover\x00front"),
+			), 0xff, 0xfb),
+			&Tags{
+				Title:   "Song",
+				Artist:  "AB",
+				Album:   "Albé",
+				Track:   3,
+				Picture: &Picture{MIME: "image/jpeg", Type: 3, Data: []byte("front")},
+			},
+			nil,
+		},
+		{
+			"flac",
+			flac("TITLE=Song", "artist=Artist", "TRACKNUMBER=7"),
+			&Tags{Title: "Song", Artist: "Artist", Track: 7},
+			nil,
+		},
+		{
+			"untagged mp3",
+			[]byte{0xff, 0xfb, 0x90, 0x64, 0x00},
+			nil,
+			ErrNoTags,
+		},
+		{
+			"truncated",
+			id3v23([]byte("TIT2"), []byte("\x03Song"))[:14],
+			nil,
+			ErrMalformed,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tags, err := Read(bytes.NewReader(tc.stream))
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.expected, tags)
+		})
+	}
+}
@@ -0,0 +1,207 @@
+// FLAC metadata blocks and Ogg Vorbis / Opus comments
+
+package tags
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// FLAC metadata block types
+const (
+	flacVorbisComment = 4
+	flacPicture       = 6
+)
+
+// Parses vorbis comments, shared by FLAC and Ogg streams
+func vorbisComments(t *Tags, b []byte) error {
+	r := bytes.NewReader(b)
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return ErrMalformed
+	}
+	if _, err := readN(r, int(n)); err != nil { // Vendor string
+		return err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return ErrMalformed
+	}
+	for count := n; count > 0; count-- {
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return ErrMalformed
+		}
+		comment, err := readN(r, int(n))
+		if err != nil {
+			return err
+		}
+		kv := strings.SplitN(string(comment), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.ToUpper(kv[0]) {
+		case "TITLE":
+			t.Title = kv[1]
+		case "ARTIST":
+			t.Artist = kv[1]
+		case "ALBUM":
+			t.Album = kv[1]
+		case "TRACKNUMBER":
+			t.Track = parseTrack(kv[1])
+		case "METADATA_BLOCK_PICTURE":
+			raw, err := base64.StdEncoding.DecodeString(kv[1])
+			if err != nil {
+				continue
+			}
+			if p, err := flacPictureBlock(raw); err == nil {
+				t.setPicture(p)
+			}
+		}
+	}
+	return nil
+}
+
+// Parses a FLAC PICTURE metadata block
+func flacPictureBlock(b []byte) (*Picture, error) {
+	r := bytes.NewReader(b)
+	var typ, n uint32
+	if err := binary.Read(r, binary.BigEndian, &typ); err != nil {
+		return nil, ErrMalformed
+	}
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, ErrMalformed
+	}
+	mime, err := readN(r, int(n))
+	if err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, ErrMalformed
+	}
+	// Description, then width, height, depth and colours
+	if _, err := readN(r, int(n)+16); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, ErrMalformed
+	}
+	data, err := readN(r, int(n))
+	if err != nil {
+		return nil, err
+	}
+	return &Picture{MIME: string(mime), Type: int(typ), Data: data}, nil
+}
+
+// Reads FLAC metadata blocks
+func readFLAC(r io.Reader) (*Tags, error) {
+	if _, err := readN(r, 4); err != nil { // fLaC
+		return nil, err
+	}
+	t := &Tags{}
+	for {
+		header, err := readN(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		last, typ := header[0]&0x80 != 0, header[0]&0x7f
+		block, err := readN(r, int(header[1])<<16|int(header[2])<<8|int(header[3]))
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case flacVorbisComment:
+			if err := vorbisComments(t, block); err != nil {
+				return nil, err
+			}
+		case flacPicture:
+			if p, err := flacPictureBlock(block); err == nil {
+				t.setPicture(p)
+			}
+		}
+		if last {
+			return t, nil
+		}
+	}
+}
+
+// Reads packets from the first logical stream of an ogg bitstream
+type oggReader struct {
+	r       io.Reader
+	serial  uint32
+	started bool
+	pending [][]byte // Complete packets not yet returned
+	partial []byte   // Packet continuing onto the next page
+}
+
+// Returns the next complete packet
+func (o *oggReader) packet() ([]byte, error) {
+	for len(o.pending) == 0 {
+		header, err := readN(o.r, 27)
+		if err != nil {
+			return nil, err
+		}
+		if string(header[:4]) != "OggS" {
+			return nil, ErrMalformed
+		}
+		serial := binary.LittleEndian.Uint32(header[14:])
+		segments, err := readN(o.r, int(header[26]))
+		if err != nil {
+			return nil, err
+		}
+		size := 0
+		for _, s := range segments {
+			size += int(s)
+		}
+		body, err := readN(o.r, size)
+		if err != nil {
+			return nil, err
+		}
+		if !o.started {
+			o.serial, o.started = serial, true
+		}
+		if serial != o.serial {
+			continue // Another logical stream
+		}
+		for _, s := range segments {
+			o.partial = append(o.partial, body[:s]...)
+			body = body[s:]
+			if s < 255 {
+				o.pending = append(o.pending, o.partial)
+				o.partial = nil
+			}
+			if len(o.partial) > maxTagSize {
+				return nil, ErrMalformed
+			}
+		}
+	}
+	p := o.pending[0]
+	o.pending = o.pending[1:]
+	return p, nil
+}
+
+// Reads the comment header of an Ogg Vorbis or Opus stream
+func readOgg(r io.Reader) (*Tags, error) {
+	o := &oggReader{r: r}
+	if _, err := o.packet(); err != nil { // Identification header
+		return nil, err
+	}
+	comment, err := o.packet()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(comment, []byte("\x03vorbis")):
+		comment = comment[7:]
+	case bytes.HasPrefix(comment, []byte("OpusTags")):
+		comment = comment[8:]
+	default:
+		return nil, ErrNoTags
+	}
+	t := &Tags{}
+	if err := vorbisComments(t, comment); err != nil {
+		return nil, err
+	}
+	return t, nil
+}